@@ -2,26 +2,19 @@ package main
 
 import (
 	"embed"
+	"fmt"
+	"os"
 
-	"webui-skeleton/internal/app"
-	"webui-skeleton/internal/logger"
+	"webui-skeleton/internal/cli"
 )
 
 //go:embed web/templates/*
 var TemplateFS embed.FS
 
 func main() {
-	// Create and initialize application
-	application := app.New(TemplateFS)
-	defer application.Cleanup()
-
-	// Initialize all components
-	if err := application.Initialize(); err != nil {
-		logger.Log.Fatal().Err(err).Msg("❌ Failed to initialize application")
-	}
-
-	// Run the application
-	if err := application.Run(); err != nil {
-		logger.Log.Fatal().Err(err).Msg("❌ Application failed to run")
+	// Run the command given on the command line; without one the server starts
+	if err := cli.Run(os.Args[1:], TemplateFS); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 }
@@ -3,22 +3,31 @@ package app
 import (
 	"context"
 	"embed"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"webui-skeleton/internal/config"
 	"webui-skeleton/internal/database"
 	"webui-skeleton/internal/logger"
+	"webui-skeleton/internal/metrics"
 	"webui-skeleton/internal/server"
+	"webui-skeleton/internal/tracing"
+	"webui-skeleton/internal/version"
 )
 
 // Application represents the main application
 type Application struct {
 	config     *config.Config
+	configs    *config.Manager
 	server     *server.Server
 	db         *database.DB
 	templateFS embed.FS
+
+	// shutdownTracing flushes the spans that haven't been exported yet
+	shutdownTracing func(context.Context) error
 }
 
 // New creates a new application instance
@@ -28,11 +37,11 @@ func New(templateFS embed.FS) *Application {
 	}
 }
 
-// Initialize sets up all application components
-func (app *Application) Initialize() error {
+// Initialize sets up all application components; args are the command line flags
+func (app *Application) Initialize(args []string) error {
 	// Load configuration and setup logger
 	var err error
-	app.config, err = config.LoadConfiguration()
+	app.config, err = config.LoadConfiguration(args)
 	if err != nil {
 		return err
 	}
@@ -43,21 +52,44 @@ func (app *Application) Initialize() error {
 	// Display banner
 	displayBanner()
 
+	// Setup tracing before anything that creates spans
+	app.shutdownTracing, err = tracing.Setup(&app.config.Tracing)
+	if err != nil {
+		return err
+	}
+
 	// Initialize database
 	app.db = database.New(&app.config.Database)
 	if err := app.db.Connect(); err != nil {
 		return err
 	}
 
+	// Expose the connection pool statistics
+	if app.config.Metrics.Enabled {
+		if err := metrics.RegisterDatabase(app.db.DB, string(app.config.Database.Type)); err != nil {
+			return err
+		}
+	}
+
 	// Run database migrations
 	if err := app.db.Migrate(); err != nil {
 		return err
 	}
 
+	// Reloads apply the rotated database password to new connections
+	app.configs = config.NewManager(app.config, args)
+	app.configs.Subscribe(func(cfg *config.Config, _ []config.Change) {
+		app.db.SetPassword(cfg.Database.Password)
+	})
+
 	// Setup server
-	app.server = server.New(app.config, app.templateFS, app.db)
-	app.server.SetupEngine()
-	app.server.CreateHTTPServer()
+	app.server = server.New(app.configs, app.templateFS, app.db)
+	if err := app.server.SetupEngine(); err != nil {
+		return err
+	}
+	if err := app.server.CreateHTTPServer(); err != nil {
+		return err
+	}
 
 	logger.Log.Info().Msg("✅ Application initialized successfully")
 	return nil
@@ -69,6 +101,12 @@ func (app *Application) Run() error {
 	ctx, cancel := setupGracefulShutdown()
 	defer cancel()
 
+	// Reload the configuration on SIGHUP and pick up rotated secrets without a restart
+	go app.watchReloadSignal(ctx)
+	if interval := app.config.Secrets.RefreshInterval; interval > 0 {
+		go app.refreshSecrets(ctx, interval)
+	}
+
 	// Start the HTTP server (this blocks until shutdown)
 	if err := app.server.Start(ctx); err != nil {
 		logger.Log.Fatal().Err(err).Msg("❌ HTTP server failed")
@@ -79,6 +117,40 @@ func (app *Application) Run() error {
 	return nil
 }
 
+// refreshSecrets re-reads the secrets every interval and applies the ones that changed
+func (app *Application) refreshSecrets(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Errors are logged, the current secrets stay in use
+			_ = app.server.ReloadSecrets()
+		}
+	}
+}
+
+// watchReloadSignal reloads the configuration on SIGHUP
+func (app *Application) watchReloadSignal(ctx context.Context) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigChan:
+			logger.Log.Info().Msg("🔄 Received SIGHUP, reloading configuration")
+			// Errors are logged, the current configuration stays in use
+			_, _ = app.server.ReloadConfig("SIGHUP")
+		}
+	}
+}
+
 // Cleanup performs cleanup operations
 func (app *Application) Cleanup() {
 	if app.db != nil {
@@ -86,18 +158,33 @@ func (app *Application) Cleanup() {
 			logger.Log.Error().Err(err).Msg("❌ Failed to close database connection")
 		}
 	}
+
+	if app.shutdownTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := app.shutdownTracing(ctx); err != nil {
+			logger.Log.Error().Err(err).Msg("❌ Failed to flush traces")
+		}
+	}
 }
 
 func displayBanner() {
+	build := version.Get()
 	banner := `
 ╔═══════════════════════════════════════╗
 ║            WebUI Skeleton             ║
 ║                                       ║
 ║    A Go web application skeleton      ║
 ║    with authentication & web UI       ║
-╚═══════════════════════════════════════╝
+║                                       ║
+` + fmt.Sprintf("║    %-35.35s║\n", build.String()) + `╚═══════════════════════════════════════╝
 `
-	logger.Log.Info().Msg(banner)
+	logger.Log.Info().
+		Str("version", build.Version).
+		Str("commit", build.Commit).
+		Bool("dirty", build.Dirty).
+		Str("go_version", build.GoVersion).
+		Msg(banner)
 }
 
 func setupGracefulShutdown() (context.Context, context.CancelFunc) {
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// APITokenPrefix marks personal access tokens so they can be told apart from JWTs
+	APITokenPrefix = "wsk_"
+
+	// ScopeRead allows safe (GET, HEAD, OPTIONS) requests
+	ScopeRead = "read"
+
+	// ScopeWrite allows every other request
+	ScopeWrite = "write"
+
+	// apiTokenLastUsedInterval limits how often last_used_at is written for a busy token
+	apiTokenLastUsedInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIToken  = errors.New("invalid or expired API token")
+	ErrAPITokenNotFound = errors.New("API token not found")
+	ErrInvalidScope     = errors.New("invalid scope")
+)
+
+// ValidScopes lists the scopes an API token can be granted
+var ValidScopes = []string{ScopeRead, ScopeWrite}
+
+// IsAPIToken reports whether a bearer token is a personal access token rather than a JWT
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// ScopeForMethod returns the scope an API token needs for an HTTP method
+func ScopeForMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ScopeRead
+	default:
+		return ScopeWrite
+	}
+}
+
+// HasScope reports whether the credentials allow scope. Sessions from an
+// interactive login are not restricted by scopes.
+func (c *JWTClaims) HasScope(scope string) bool {
+	return c.TokenID == 0 || slices.Contains(c.Scopes, scope)
+}
+
+// CreateAPIToken creates a personal access token for a user and returns it together
+// with the secret token string, which is not stored and can't be shown again
+func (s *Service) CreateAPIToken(ctx context.Context, userID int, name string, scopes []string, expiresAt *time.Time) (*APIToken, string, error) {
+	for _, scope := range scopes {
+		if !slices.Contains(ValidScopes, scope) {
+			return nil, "", fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+
+	id, err := randomString(4)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate token prefix: %w", err)
+	}
+	secret, err := randomString(32)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	salt, err := randomString(16)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate token salt: %w", err)
+	}
+
+	prefix := APITokenPrefix + id
+	var expires interface{}
+	if expiresAt != nil {
+		expires = expiresAt.UTC()
+	}
+
+	tokenID, err := s.db.InsertIDContext(ctx, `
+		INSERT INTO api_tokens (user_id, name, prefix, salt, token_hash, scopes, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID, name, prefix, salt, hashAPITokenSecret(salt, secret), strings.Join(scopes, ","), expires)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create API token: %w", err)
+	}
+
+	token := &APIToken{
+		ID:        int(tokenID),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	return token, prefix + "_" + secret, nil
+}
+
+// ListAPITokens returns a user's API tokens, newest first
+func (s *Service) ListAPITokens(ctx context.Context, userID int) ([]APIToken, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_tokens WHERE user_id = ? ORDER BY id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		var (
+			token      APIToken
+			scopes     string
+			expiresAt  sql.NullTime
+			lastUsedAt sql.NullTime
+		)
+		if err := rows.Scan(&token.ID, &token.UserID, &token.Name, &token.Prefix, &scopes,
+			&expiresAt, &lastUsedAt, &token.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		token.Scopes = splitScopes(scopes)
+		if expiresAt.Valid {
+			token.ExpiresAt = &expiresAt.Time
+		}
+		if lastUsedAt.Valid {
+			token.LastUsedAt = &lastUsedAt.Time
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// RevokeAPIToken deletes one of a user's API tokens
+func (s *Service) RevokeAPIToken(ctx context.Context, userID, tokenID int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, tokenID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API token: %w", err)
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to revoke API token: %w", err)
+	} else if affected == 0 {
+		return ErrAPITokenNotFound
+	}
+
+	return nil
+}
+
+// AuthenticateAPIToken validates a personal access token and records its use
+func (s *Service) AuthenticateAPIToken(ctx context.Context, token string) (*JWTClaims, error) {
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(token, APITokenPrefix), "_")
+	if !IsAPIToken(token) || !ok || secret == "" {
+		return nil, ErrInvalidAPIToken
+	}
+
+	var (
+		tokenID    int
+		userID     int
+		salt       string
+		tokenHash  string
+		scopes     string
+		expiresAt  sql.NullTime
+		lastUsedAt sql.NullTime
+	)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, user_id, salt, token_hash, scopes, expires_at, last_used_at
+		FROM api_tokens WHERE prefix = ?`,
+		APITokenPrefix+prefix).Scan(&tokenID, &userID, &salt, &tokenHash, &scopes, &expiresAt, &lastUsedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidAPIToken
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to query API token: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashAPITokenSecret(salt, secret)), []byte(tokenHash)) != 1 {
+		return nil, ErrInvalidAPIToken
+	}
+
+	if expiresAt.Valid && time.Now().After(expiresAt.Time) {
+		return nil, ErrInvalidAPIToken
+	}
+
+	if !lastUsedAt.Valid || time.Since(lastUsedAt.Time) > apiTokenLastUsedInterval {
+		if _, err := s.db.ExecContext(ctx, `
+			UPDATE api_tokens SET last_used_at = ? WHERE id = ?`,
+			time.Now().UTC(), tokenID); err != nil {
+			return nil, fmt.Errorf("failed to update API token: %w", err)
+		}
+	}
+
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Blocked() {
+		return nil, ErrUserDisabled
+	}
+
+	roles, err := s.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JWTClaims{
+		UserID:  user.ID,
+		Email:   user.Email,
+		Name:    user.Name,
+		Roles:   roles,
+		TokenID: tokenID,
+		Scopes:  splitScopes(scopes),
+	}, nil
+}
+
+// hashAPITokenSecret returns the salted SHA-256 of an API token secret as hex
+func hashAPITokenSecret(salt, secret string) string {
+	sum := sha256.Sum256([]byte(salt + secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// splitScopes parses the comma-separated scopes column
+func splitScopes(scopes string) []string {
+	if scopes == "" {
+		return []string{}
+	}
+	return strings.Split(scopes, ",")
+}
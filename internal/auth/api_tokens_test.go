@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lastUsedAt returns an API token's last_used_at column
+func lastUsedAt(t *testing.T, s *Service, tokenID int) sql.NullTime {
+	t.Helper()
+	var lastUsed sql.NullTime
+	if err := s.db.QueryRow(`SELECT last_used_at FROM api_tokens WHERE id = ?`, tokenID).Scan(&lastUsed); err != nil {
+		t.Fatal(err)
+	}
+	return lastUsed
+}
+
+func TestAPITokenMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	s := newTestService(t)
+	user := newTestUser(t, s, "alice@example.com")
+
+	_, readToken, err := s.CreateAPIToken(ctx, user.ID, "ci", []string{ScopeRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, writeToken, err := s.CreateAPIToken(ctx, user.ID, "deploy", []string{ScopeRead, ScopeWrite}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(readToken, APITokenPrefix) || strings.Count(readToken, "_") != 2 {
+		t.Fatalf("token = %q, want wsk_<prefix>_<secret>", readToken)
+	}
+
+	engine := gin.New()
+	engine.Use(s.Middleware())
+	engine.Any("/api/items", func(c *gin.Context) {
+		userID, _, _, _ := GetUserFromContext(c)
+		scopes, _ := GetTokenScopesFromContext(c)
+		c.JSON(http.StatusOK, gin.H{"user_id": userID, "scopes": scopes})
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		wantStatus int
+	}{
+		{"read token GET", http.MethodGet, readToken, http.StatusOK},
+		{"read token HEAD", http.MethodHead, readToken, http.StatusOK},
+		{"read token POST", http.MethodPost, readToken, http.StatusForbidden},
+		{"read token DELETE", http.MethodDelete, readToken, http.StatusForbidden},
+		{"write token POST", http.MethodPost, writeToken, http.StatusOK},
+		{"write token DELETE", http.MethodDelete, writeToken, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/items", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusForbidden && !strings.Contains(w.Body.String(), "insufficient_scope") {
+				t.Errorf("body = %s, want the insufficient_scope code", w.Body)
+			}
+			if tt.wantStatus == http.StatusOK && tt.method != http.MethodHead {
+				var body struct {
+					UserID int      `json:"user_id"`
+					Scopes []string `json:"scopes"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				if body.UserID != user.ID || len(body.Scopes) == 0 {
+					t.Errorf("context = %+v, want user %d with the token's scopes", body, user.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPITokenRejects(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	user := newTestUser(t, s, "alice@example.com")
+
+	valid, secret, err := s.CreateAPIToken(ctx, user.ID, "ci", []string{ScopeRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Minute)
+	_, expired, err := s.CreateAPIToken(ctx, user.ID, "old", []string{ScopeRead}, &past)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedSecret, err := s.CreateAPIToken(ctx, user.ID, "revoked", []string{ScopeRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RevokeAPIToken(ctx, user.ID, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"wrong secret", valid.Prefix + "_" + strings.Repeat("0", 64)},
+		{"unknown prefix", APITokenPrefix + "00000000_" + strings.TrimPrefix(secret, valid.Prefix+"_")},
+		{"missing secret", valid.Prefix + "_"},
+		{"missing separator", valid.Prefix},
+		{"not an API token", "eyJhbGciOiJIUzI1NiJ9.e30.signature"},
+		{"expired", expired},
+		{"revoked", revokedSecret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.AuthenticateAPIToken(ctx, tt.token); !errors.Is(err, ErrInvalidAPIToken) {
+				t.Errorf("AuthenticateAPIToken() error = %v, want %v", err, ErrInvalidAPIToken)
+			}
+		})
+	}
+
+	// A disabled owner's tokens stop working until the account is enabled again
+	if err := s.DisableUser(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticateAPIToken(ctx, secret); !errors.Is(err, ErrUserDisabled) {
+		t.Errorf("AuthenticateAPIToken() for a disabled user error = %v, want %v", err, ErrUserDisabled)
+	}
+	if err := s.EnableUser(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticateAPIToken(ctx, secret); err != nil {
+		t.Errorf("AuthenticateAPIToken() after enabling the user error = %v", err)
+	}
+
+	if _, _, err := s.CreateAPIToken(ctx, user.ID, "admin", []string{"admin"}, nil); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("CreateAPIToken() with an unknown scope error = %v, want %v", err, ErrInvalidScope)
+	}
+}
+
+func TestAuthenticateAPITokenLastUsed(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	token, secret, err := s.CreateAPIToken(ctx, newTestUser(t, s, "alice@example.com").ID, "ci", []string{ScopeRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastUsedAt(t, s, token.ID).Valid {
+		t.Fatal("last_used_at is set before the token was used")
+	}
+
+	if _, err := s.AuthenticateAPIToken(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	first := lastUsedAt(t, s, token.ID)
+	if !first.Valid {
+		t.Fatal("last_used_at not set by the first use")
+	}
+
+	// Uses within the interval don't write again
+	if _, err := s.AuthenticateAPIToken(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if second := lastUsedAt(t, s, token.ID); !second.Time.Equal(first.Time) {
+		t.Errorf("last_used_at = %v after a second use, want it left at %v", second.Time, first.Time)
+	}
+
+	backdated := time.Now().Add(-apiTokenLastUsedInterval - time.Second).UTC()
+	if _, err := s.db.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, backdated, token.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticateAPIToken(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if third := lastUsedAt(t, s, token.ID); !third.Time.After(backdated) {
+		t.Errorf("last_used_at = %v after the interval, want it updated", third.Time)
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"strings"
+
+	"webui-skeleton/internal/config"
+)
+
+// ErrUnknownPrincipal is returned for a client certificate mapped to an email without an account
+var ErrUnknownPrincipal = errors.New("client certificate principal has no account")
+
+// parseClientCertPrincipals maps client certificate identities to account emails;
+// entries are checked when the configuration is validated, so invalid ones are skipped
+func parseClientCertPrincipals(entries []string) map[string]string {
+	principals := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if identity, email, err := config.ParseClientCertPrincipal(entry); err == nil {
+			principals[identity] = email
+		}
+	}
+	return principals
+}
+
+// certIdentities lists the identities of a certificate in the form used by
+// auth.client_cert_principals, subject alternative names first
+func certIdentities(cert *x509.Certificate) []string {
+	var identities []string
+	for _, uri := range cert.URIs {
+		identities = append(identities, config.CertIdentityURI+":"+uri.String())
+	}
+	for _, name := range cert.DNSNames {
+		identities = append(identities, config.CertIdentityDNS+":"+strings.ToLower(name))
+	}
+	for _, address := range cert.EmailAddresses {
+		identities = append(identities, config.CertIdentityEmail+":"+address)
+	}
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, config.CertIdentityCN+":"+cert.Subject.CommonName)
+	}
+	return identities
+}
+
+// clientCertPrincipal returns the identity and account email of the service that
+// presented the client certificate of a connection. ok is false when the client sent no
+// certificate, or one that no principal is mapped from. The TLS server has already
+// verified the certificate against server.tls.client_ca_file.
+func (s *Service) clientCertPrincipal(state *tls.ConnectionState) (identity, email string, ok bool) {
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return "", "", false
+	}
+
+	s.liveMu.RLock()
+	defer s.liveMu.RUnlock()
+	for _, identity := range certIdentities(state.VerifiedChains[0][0]) {
+		if email, ok := s.clientCertPrincipals[identity]; ok {
+			return identity, email, true
+		}
+	}
+	return "", "", false
+}
+
+// authenticateClientCert builds the claims of the service account that identity is mapped to;
+// its roles are those assigned to the account
+func (s *Service) authenticateClientCert(ctx context.Context, identity, email string) (*JWTClaims, error) {
+	user, err := s.GetUserByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrUnknownPrincipal
+	} else if err != nil {
+		return nil, err
+	}
+	if user.Blocked() {
+		return nil, ErrUserDisabled
+	}
+
+	roles, err := s.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JWTClaims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Roles:     roles,
+		Principal: identity,
+	}, nil
+}
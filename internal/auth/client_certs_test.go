@@ -0,0 +1,322 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testCA issues client certificates
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// issue signs a client certificate with the subject and alternative names of template
+func (ca *testCA) issue(t *testing.T, template x509.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	der, err := x509.CreateCertificate(rand.Reader, &template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestClientCertAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	s := newTestService(t)
+	s.clientCertPrincipals = parseClientCertPrincipals([]string{
+		"uri:spiffe://example.org/billing=billing@services.example.org",
+		"dns:reports.internal.example.org=reports@services.example.org",
+		"email:ops-bot@example.org=ops@services.example.org",
+		"cn:legacy-batch=batch@services.example.org",
+		"cn:disabled-job=disabled@services.example.org",
+		"cn:deleted-job=deleted@services.example.org",
+		"cn:removed-job=removed@services.example.org",
+		"cn:unprovisioned-job=nobody@services.example.org",
+	})
+
+	users := map[string]*User{}
+	for _, name := range []string{"billing", "reports", "ops", "batch", "disabled", "deleted", "removed", "alice"} {
+		domain := "services.example.org"
+		if name == "alice" {
+			domain = "example.com"
+		}
+		users[name] = newTestUser(t, s, name+"@"+domain)
+	}
+	if err := s.DisableUser(ctx, users["disabled"].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SoftDeleteUser(ctx, users["deleted"].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteUser(ctx, users["removed"].ID); err != nil {
+		t.Fatal(err)
+	}
+	_, aliceToken, err := s.CreateAPIToken(ctx, users["alice"].ID, "cli", []string{ScopeRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := gin.New()
+	engine.Use(s.Middleware())
+	engine.GET("/api/whoami", func(c *gin.Context) {
+		userID, _ := c.Get("user_id")
+		principal, _ := GetServicePrincipalFromContext(c)
+		c.JSON(http.StatusOK, gin.H{"user_id": userID, "principal": principal})
+	})
+
+	ca := newTestCA(t, "Services CA")
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	server := httptest.NewUnstartedServer(engine)
+	server.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.VerifyClientCertIfGiven}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	// get requests /api/whoami presenting cert, if any, and token as a bearer token. The
+	// certificate is sent even when the server doesn't list its issuer as acceptable.
+	get := func(t *testing.T, cert *tls.Certificate, token string) (*http.Response, error) {
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		if cert != nil {
+			transport.TLSClientConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return cert, nil
+			}
+		}
+		defer transport.CloseIdleConnections()
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/api/whoami", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return (&http.Client{Transport: transport}).Do(req)
+	}
+
+	cert := func(template x509.Certificate) *tls.Certificate {
+		issued := ca.issue(t, template)
+		return &issued
+	}
+	tests := []struct {
+		name          string
+		cert          *tls.Certificate
+		token         string
+		wantStatus    int
+		wantCode      string
+		wantUser      string
+		wantPrincipal string
+	}{
+		{
+			name:          "URI SAN",
+			cert:          cert(x509.Certificate{URIs: []*url.URL{mustParseURL(t, "spiffe://example.org/billing")}}),
+			wantStatus:    http.StatusOK,
+			wantUser:      "billing",
+			wantPrincipal: "uri:spiffe://example.org/billing",
+		},
+		{
+			name:          "DNS SAN in any case",
+			cert:          cert(x509.Certificate{DNSNames: []string{"Reports.Internal.Example.org"}}),
+			wantStatus:    http.StatusOK,
+			wantUser:      "reports",
+			wantPrincipal: "dns:reports.internal.example.org",
+		},
+		{
+			name:          "email SAN",
+			cert:          cert(x509.Certificate{EmailAddresses: []string{"ops-bot@example.org"}}),
+			wantStatus:    http.StatusOK,
+			wantUser:      "ops",
+			wantPrincipal: "email:ops-bot@example.org",
+		},
+		{
+			name:          "common name",
+			cert:          cert(x509.Certificate{Subject: pkix.Name{CommonName: "legacy-batch"}}),
+			wantStatus:    http.StatusOK,
+			wantUser:      "batch",
+			wantPrincipal: "cn:legacy-batch",
+		},
+		{
+			name: "SAN before common name",
+			cert: cert(x509.Certificate{
+				Subject: pkix.Name{CommonName: "legacy-batch"},
+				URIs:    []*url.URL{mustParseURL(t, "spiffe://example.org/billing")},
+			}),
+			wantStatus:    http.StatusOK,
+			wantUser:      "billing",
+			wantPrincipal: "uri:spiffe://example.org/billing",
+		},
+		{
+			name:          "certificate over bearer token",
+			cert:          cert(x509.Certificate{Subject: pkix.Name{CommonName: "legacy-batch"}}),
+			token:         aliceToken,
+			wantStatus:    http.StatusOK,
+			wantUser:      "batch",
+			wantPrincipal: "cn:legacy-batch",
+		},
+		{
+			name:       "unmapped certificate falls back to the token",
+			cert:       cert(x509.Certificate{Subject: pkix.Name{CommonName: "someone-else"}}),
+			token:      aliceToken,
+			wantStatus: http.StatusOK,
+			wantUser:   "alice",
+		},
+		{
+			name:       "unmapped certificate without a token",
+			cert:       cert(x509.Certificate{Subject: pkix.Name{CommonName: "someone-else"}}),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "no certificate",
+			token:      aliceToken,
+			wantStatus: http.StatusOK,
+			wantUser:   "alice",
+		},
+		{
+			name:       "disabled account",
+			cert:       cert(x509.Certificate{Subject: pkix.Name{CommonName: "disabled-job"}}),
+			token:      aliceToken,
+			wantStatus: http.StatusForbidden,
+			wantCode:   "account_disabled",
+		},
+		{
+			name:       "soft-deleted account",
+			cert:       cert(x509.Certificate{Subject: pkix.Name{CommonName: "deleted-job"}}),
+			wantStatus: http.StatusForbidden,
+			wantCode:   "account_disabled",
+		},
+		{
+			name:       "deleted account",
+			cert:       cert(x509.Certificate{Subject: pkix.Name{CommonName: "removed-job"}}),
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "unknown_principal",
+		},
+		{
+			name:       "no account",
+			cert:       cert(x509.Certificate{Subject: pkix.Name{CommonName: "unprovisioned-job"}}),
+			token:      aliceToken,
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "unknown_principal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := get(t, tt.cert, tt.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var body struct {
+				UserID    int    `json:"user_id"`
+				Principal string `json:"principal"`
+				Code      string `json:"code"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.wantStatus || body.Code != tt.wantCode {
+				t.Fatalf("response = %d %q, want %d %q", resp.StatusCode, body.Code, tt.wantStatus, tt.wantCode)
+			}
+			if tt.wantUser == "" {
+				return
+			}
+			if body.UserID != users[tt.wantUser].ID || body.Principal != tt.wantPrincipal {
+				t.Errorf("authenticated as user %d principal %q, want user %d (%s) principal %q",
+					body.UserID, body.Principal, users[tt.wantUser].ID, tt.wantUser, tt.wantPrincipal)
+			}
+		})
+	}
+
+	// A certificate from another CA fails the handshake rather than falling back
+	other := newTestCA(t, "Other CA").issue(t, x509.Certificate{Subject: pkix.Name{CommonName: "legacy-batch"}})
+	if resp, err := get(t, &other, aliceToken); err == nil {
+		resp.Body.Close()
+		t.Errorf("request with a certificate from another CA = %d, want a failed handshake", resp.StatusCode)
+	}
+}
+
+func TestClientCertPrincipalRequiresVerifiedChain(t *testing.T) {
+	s := newTestService(t)
+	s.clientCertPrincipals = parseClientCertPrincipals([]string{"cn:legacy-batch=batch@services.example.org"})
+
+	ca := newTestCA(t, "Services CA")
+	issued := ca.issue(t, x509.Certificate{Subject: pkix.Name{CommonName: "legacy-batch"}})
+	leaf, err := x509.ParseCertificate(issued.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only chains the TLS server verified count, not certificates merely presented
+	if _, _, ok := s.clientCertPrincipal(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}); ok {
+		t.Error("clientCertPrincipal() accepted an unverified certificate")
+	}
+	if _, _, ok := s.clientCertPrincipal(nil); ok {
+		t.Error("clientCertPrincipal() accepted a plain HTTP request")
+	}
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf, ca.cert}}}
+	if identity, email, ok := s.clientCertPrincipal(verified); !ok || identity != "cn:legacy-batch" || email != "batch@services.example.org" {
+		t.Errorf("clientCertPrincipal() = %q, %q, %t, want cn:legacy-batch mapped to batch@services.example.org", identity, email, ok)
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddlewareCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestService(t)
+	pair, err := s.IssueTokens(context.Background(), newTestUser(t, s, "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := gin.New()
+	engine.Use(s.Middleware())
+	engine.Any("/admin/action", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	accessCookie := &http.Cookie{Name: AccessCookieName, Value: pair.AccessToken}
+	refreshCookie := &http.Cookie{Name: RefreshCookieName, Value: pair.RefreshToken}
+
+	tests := []struct {
+		name       string
+		method     string
+		cookies    []*http.Cookie
+		bearer     string
+		header     bool
+		wantStatus int
+	}{
+		{name: "cookie GET", method: http.MethodGet, cookies: []*http.Cookie{accessCookie}, wantStatus: http.StatusNoContent},
+		{name: "cookie HEAD", method: http.MethodHead, cookies: []*http.Cookie{accessCookie}, wantStatus: http.StatusNoContent},
+		{name: "cookie POST with header", method: http.MethodPost, cookies: []*http.Cookie{accessCookie}, header: true, wantStatus: http.StatusNoContent},
+		{name: "cookie POST without header", method: http.MethodPost, cookies: []*http.Cookie{accessCookie}, wantStatus: http.StatusForbidden},
+		{name: "cookie DELETE without header", method: http.MethodDelete, cookies: []*http.Cookie{accessCookie}, wantStatus: http.StatusForbidden},
+		{name: "refresh cookie POST without header", method: http.MethodPost, cookies: []*http.Cookie{refreshCookie}, wantStatus: http.StatusForbidden},
+		{name: "bearer POST without header", method: http.MethodPost, bearer: pair.AccessToken, wantStatus: http.StatusNoContent},
+		{name: "no credentials", method: http.MethodPost, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/admin/action", nil)
+			for _, cookie := range tt.cookies {
+				req.AddCookie(cookie)
+			}
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if tt.header {
+				req.Header.Set(CSRFHeader, "XMLHttpRequest")
+			}
+
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+
+	// The rejected request must not have rotated the refresh token
+	if _, err := s.RefreshTokens(context.Background(), pair.RefreshToken); err != nil {
+		t.Errorf("RefreshTokens() after the rejected requests error = %v", err)
+	}
+}
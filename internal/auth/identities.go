@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrAccountExists    = errors.New("an account with this email already exists")
+	ErrIdentityLinked   = errors.New("identity is linked to another user")
+	ErrIdentityNotFound = errors.New("identity not found")
+	ErrLastIdentity     = errors.New("cannot unlink the last identity")
+)
+
+// LinkIdentity attaches an external identity to a user.
+// Linking an identity the user already owns is a no-op.
+func (s *Service) LinkIdentity(ctx context.Context, userID int, identity *ExternalIdentity) error {
+	var ownerID int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?`,
+		identity.Provider, identity.Subject).Scan(&ownerID)
+
+	if err == nil {
+		if ownerID != userID {
+			return ErrIdentityLinked
+		}
+		return nil
+	} else if err != sql.ErrNoRows {
+		return fmt.Errorf("failed to query identity: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_identities (user_id, provider, subject, email, email_verified)
+		VALUES (?, ?, ?, ?, ?)`,
+		userID, identity.Provider, identity.Subject, identity.Email, identity.EmailVerified); err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return nil
+}
+
+// UnlinkIdentity removes one of the user's identities, refusing to remove the last one
+func (s *Service) UnlinkIdentity(ctx context.Context, userID, identityID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRow(`
+		SELECT COUNT(*) FROM user_identities WHERE user_id = ?`, userID).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count identities: %w", err)
+	}
+
+	result, err := tx.Exec(`
+		DELETE FROM user_identities WHERE id = ? AND user_id = ?`, identityID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unlink identity: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to unlink identity: %w", err)
+	}
+	if affected == 0 {
+		return ErrIdentityNotFound
+	}
+	if count <= 1 {
+		return ErrLastIdentity
+	}
+
+	return tx.Commit()
+}
+
+// ListIdentities returns the identities linked to a user
+func (s *Service) ListIdentities(ctx context.Context, userID int) ([]UserIdentity, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, provider, subject, email, email_verified, linked_at
+		FROM user_identities WHERE user_id = ? ORDER BY linked_at, id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	defer rows.Close()
+
+	identities := []UserIdentity{}
+	for rows.Next() {
+		var identity UserIdentity
+		if err := rows.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject,
+			&identity.Email, &identity.EmailVerified, &identity.LinkedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan identity: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
@@ -0,0 +1,378 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"webui-skeleton/internal/config"
+)
+
+// signingKey is one key of the key set. Retired keys no longer sign but still verify
+// until the overlap window has passed.
+type signingKey struct {
+	kid       string
+	private   crypto.Signer
+	public    crypto.PublicKey
+	createdAt time.Time
+	retiredAt time.Time
+}
+
+// KeyManager signs and verifies the JWTs issued by this service
+type KeyManager struct {
+	method jwt.SigningMethod
+
+	// HS256 signs with hmacSecret; after a rotation the previous secret verifies until the overlap window has passed
+	hmacSecret         []byte
+	previousHMACSecret []byte
+	hmacRotatedAt      time.Time
+
+	// Key rotation only applies to generated keys; keys from files are rotated by the operator
+	generated        bool
+	rotationInterval time.Duration
+	overlap          time.Duration
+
+	mu   sync.RWMutex
+	keys []*signingKey // keys[0] is the current signing key
+}
+
+// NewKeyManager creates the key manager for the configured algorithm, loading
+// private keys from PEM files or generating one when none are configured
+func NewKeyManager(cfg *config.AuthConfig) (*KeyManager, error) {
+	method := jwt.GetSigningMethod(cfg.JWTAlgorithm)
+	if method == nil {
+		return nil, fmt.Errorf("unsupported JWT algorithm: %s", cfg.JWTAlgorithm)
+	}
+
+	m := &KeyManager{
+		method:           method,
+		rotationInterval: cfg.JWTKeyRotationInterval,
+		overlap:          cfg.JWTKeyOverlap,
+	}
+
+	if cfg.JWTAlgorithm == jwt.SigningMethodHS256.Alg() {
+		m.hmacSecret = []byte(cfg.JWTSecret)
+		return m, nil
+	}
+
+	if len(cfg.JWTPrivateKeyFiles) == 0 {
+		m.generated = true
+		key, err := m.generateKey()
+		if err != nil {
+			return nil, err
+		}
+		m.keys = []*signingKey{key}
+		return m, nil
+	}
+
+	// The first file signs, the rest only verify so tokens signed before a manual rotation stay valid
+	for i, path := range cfg.JWTPrivateKeyFiles {
+		key, err := m.loadKey(path)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			key.retiredAt = time.Now()
+		}
+		m.keys = append(m.keys, key)
+	}
+
+	return m, nil
+}
+
+// Algorithm returns the JWS algorithm used to sign tokens
+func (m *KeyManager) Algorithm() string {
+	return m.method.Alg()
+}
+
+// Sign signs claims with the current key, rotating it first when it is due
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(m.method, claims)
+	if m.isHMAC() {
+		m.mu.RLock()
+		secret := m.hmacSecret
+		m.mu.RUnlock()
+		return token.SignedString(secret)
+	}
+
+	if err := m.rotateIfDue(); err != nil {
+		return "", err
+	}
+
+	m.mu.RLock()
+	current := m.keys[0]
+	m.mu.RUnlock()
+
+	token.Header["kid"] = current.kid
+	return token.SignedString(current.private)
+}
+
+// Keyfunc resolves the verification key for a token by its kid header
+func (m *KeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != m.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.isHMAC() {
+		if m.previousHMACSecret != nil && time.Since(m.hmacRotatedAt) < m.overlap {
+			return jwt.VerificationKeySet{Keys: []jwt.VerificationKey{m.hmacSecret, m.previousHMACSecret}}, nil
+		}
+		return m.hmacSecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	for _, key := range m.keys {
+		if key.kid == kid && m.verifies(key) {
+			return key.public, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// SetHMACSecret replaces the HS256 secret. Tokens signed with the previous
+// secret keep verifying until the key overlap window has passed.
+func (m *KeyManager) SetHMACSecret(secret string) {
+	if !m.isHMAC() {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if string(m.hmacSecret) == secret {
+		return
+	}
+	m.previousHMACSecret = m.hmacSecret
+	m.hmacSecret = []byte(secret)
+	m.hmacRotatedAt = time.Now()
+}
+
+// isHMAC reports whether tokens are signed with a shared secret instead of a key pair
+func (m *KeyManager) isHMAC() bool {
+	return m.method == jwt.SigningMethodHS256
+}
+
+// JWKS returns the public keys that currently verify tokens
+func (m *KeyManager) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, key := range m.keys {
+		if !m.verifies(key) {
+			continue
+		}
+		jwk, err := publicJWK(key.kid, m.method.Alg(), key.public)
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// verifies reports whether a key is current or still inside the overlap window
+func (m *KeyManager) verifies(key *signingKey) bool {
+	return key.retiredAt.IsZero() || !m.generated || time.Since(key.retiredAt) < m.overlap
+}
+
+// rotateIfDue replaces a generated signing key older than the rotation interval
+// and drops retired keys whose overlap window has passed
+func (m *KeyManager) rotateIfDue() error {
+	if !m.generated || m.rotationInterval <= 0 {
+		return nil
+	}
+
+	m.mu.RLock()
+	due := time.Since(m.keys[0].createdAt) >= m.rotationInterval
+	m.mu.RUnlock()
+	if !due {
+		return nil
+	}
+
+	key, err := m.generateKey()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Another request may have rotated while we generated
+	if time.Since(m.keys[0].createdAt) < m.rotationInterval {
+		return nil
+	}
+
+	m.keys[0].retiredAt = time.Now()
+	keys := []*signingKey{key}
+	for _, old := range m.keys {
+		if m.verifies(old) {
+			keys = append(keys, old)
+		}
+	}
+	m.keys = keys
+	return nil
+}
+
+// generateKey creates a new private key for the configured algorithm
+func (m *KeyManager) generateKey() (*signingKey, error) {
+	var private crypto.Signer
+	var err error
+
+	switch m.method.Alg() {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("cannot generate keys for JWT algorithm %s", m.method.Alg())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	return newSigningKey(private, m.method.Alg())
+}
+
+// loadKey reads a PEM encoded private key from disk
+func (m *KeyManager) loadKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key %s: %w", path, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in signing key %s", path)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", path, err)
+	}
+
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type in %s", path)
+	}
+
+	key, err := newSigningKey(private, m.method.Alg())
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// newSigningKey checks the key matches alg and derives its kid
+func newSigningKey(private crypto.Signer, alg string) (*signingKey, error) {
+	public := private.Public()
+	jwk, err := publicJWK("", alg, public)
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := jwkThumbprint(jwk)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signingKey{
+		kid:       kid,
+		private:   private,
+		public:    public,
+		createdAt: time.Now(),
+	}, nil
+}
+
+// publicJWK encodes a public key as a JWK, checking that it fits alg
+func publicJWK(kid, alg string, public crypto.PublicKey) (JSONWebKey, error) {
+	jwk := JSONWebKey{Kid: kid, Use: "sig", Alg: alg}
+
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			return JSONWebKey{}, fmt.Errorf("RSA key cannot be used with %s", alg)
+		}
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || key.Curve != elliptic.P256() {
+			return JSONWebKey{}, fmt.Errorf("EC key cannot be used with %s, ES256 needs a P-256 key", alg)
+		}
+		ecdhKey, err := key.ECDH()
+		if err != nil {
+			return JSONWebKey{}, fmt.Errorf("invalid EC key: %w", err)
+		}
+		// Uncompressed point: 0x04 || X || Y
+		point := ecdhKey.Bytes()
+		size := (len(point) - 1) / 2
+		jwk.Kty = "EC"
+		jwk.Crv = "P-256"
+		jwk.X = base64.RawURLEncoding.EncodeToString(point[1 : 1+size])
+		jwk.Y = base64.RawURLEncoding.EncodeToString(point[1+size:])
+	case ed25519.PublicKey:
+		if alg != "EdDSA" {
+			return JSONWebKey{}, fmt.Errorf("Ed25519 key cannot be used with %s", alg)
+		}
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(key)
+	default:
+		return JSONWebKey{}, fmt.Errorf("unsupported public key type %T", public)
+	}
+
+	return jwk, nil
+}
+
+// jwkThumbprint computes the RFC 7638 SHA-256 thumbprint of a JWK, used as its kid
+func jwkThumbprint(jwk JSONWebKey) (string, error) {
+	// Members must be in lexicographic order, which map encoding guarantees
+	members := map[string]string{"kty": jwk.Kty}
+	switch jwk.Kty {
+	case "RSA":
+		members["e"] = jwk.E
+		members["n"] = jwk.N
+	case "EC":
+		members["crv"] = jwk.Crv
+		members["x"] = jwk.X
+		members["y"] = jwk.Y
+	case "OKP":
+		members["crv"] = jwk.Crv
+		members["x"] = jwk.X
+	}
+
+	canonical, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -1,73 +1,63 @@
 package auth
 
 import (
-	"net/http"
-	"strings"
-
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"webui-skeleton/internal/logger"
 )
 
-// AuthMiddleware creates a middleware for JWT authentication
-func (s *Service) AuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Get token from Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
-			return
-		}
-
-		// Extract token from "Bearer <token>"
-		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
-			return
-		}
-
-		token := tokenParts[1]
+// setUserContext stores the authenticated user's information in the Gin context
+func setUserContext(c *gin.Context, claims *JWTClaims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("user_email", claims.Email)
+	c.Set("user_name", claims.Name)
+	c.Set("session_id", claims.SessionID)
+	c.Set("user_roles", claims.Roles)
+	if claims.TokenID != 0 {
+		c.Set("api_token_id", claims.TokenID)
+		c.Set("token_scopes", claims.Scopes)
+	}
+	if claims.Principal != "" {
+		c.Set("service_principal", claims.Principal)
+	}
+	c.Set("authenticated", true)
 
-		// Validate token
-		claims, err := s.ValidateJWT(token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
+	// Later log lines of this request, including the access log, name the user
+	logger.FromContext(c.Request.Context()).UpdateContext(func(l zerolog.Context) zerolog.Context {
+		l = l.Int("user_id", claims.UserID)
+		if claims.Principal != "" {
+			l = l.Str("service_principal", claims.Principal)
 		}
+		return l
+	})
+}
 
-		// Set user information in context
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_name", claims.Name)
+// GetServicePrincipalFromContext returns the client certificate identity that
+// authenticated the request; ok is false for requests authenticated by a token
+func GetServicePrincipalFromContext(c *gin.Context) (identity string, ok bool) {
+	identity = c.GetString("service_principal")
+	return identity, identity != ""
+}
 
-		c.Next()
+// GetTokenScopesFromContext returns the scopes of the API token that authenticated
+// the request; ok is false for requests authenticated by an interactive session
+func GetTokenScopesFromContext(c *gin.Context) (scopes []string, ok bool) {
+	value, exists := c.Get("token_scopes")
+	if !exists {
+		return nil, false
 	}
+	scopes, ok = value.([]string)
+	return scopes, ok
 }
 
-// OptionalAuthMiddleware creates a middleware that doesn't require authentication but sets user context if available
-func (s *Service) OptionalAuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Get token from Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader != "" {
-			// Extract token from "Bearer <token>"
-			tokenParts := strings.Split(authHeader, " ")
-			if len(tokenParts) == 2 && tokenParts[0] == "Bearer" {
-				token := tokenParts[1]
-
-				// Validate token
-				if claims, err := s.ValidateJWT(token); err == nil {
-					// Set user information in context
-					c.Set("user_id", claims.UserID)
-					c.Set("user_email", claims.Email)
-					c.Set("user_name", claims.Name)
-				}
-			}
-		}
-
-		c.Next()
+// GetSessionIDFromContext retrieves the current session ID from the Gin context
+func GetSessionIDFromContext(c *gin.Context) (string, bool) {
+	sessionID, ok := c.Get("session_id")
+	if !ok {
+		return "", false
 	}
+	id, ok := sessionID.(string)
+	return id, ok && id != ""
 }
 
 // GetUserFromContext retrieves user information from the Gin context
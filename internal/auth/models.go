@@ -6,35 +6,92 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID        int       `json:"id" db:"id"`
-	GoogleID  string    `json:"google_id" db:"google_id"`
-	Email     string    `json:"email" db:"email"`
-	Name      string    `json:"name" db:"name"`
-	Picture   string    `json:"picture" db:"picture"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID         int        `json:"id" db:"id"`
+	Email      string     `json:"email" db:"email"`
+	Name       string     `json:"name" db:"name"`
+	Picture    string     `json:"picture" db:"picture"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	DisabledAt *time.Time `json:"disabled_at" db:"disabled_at"`
+	DeletedAt  *time.Time `json:"deleted_at" db:"deleted_at"`
+}
+
+// Blocked reports whether the user is disabled or soft-deleted and may not log in
+func (u *User) Blocked() bool {
+	return u.DisabledAt != nil || u.DeletedAt != nil
+}
+
+// UserIdentity represents an external identity linked to a user
+type UserIdentity struct {
+	ID            int       `json:"id" db:"id"`
+	UserID        int       `json:"user_id" db:"user_id"`
+	Provider      string    `json:"provider" db:"provider"`
+	Subject       string    `json:"subject" db:"subject"`
+	Email         string    `json:"email" db:"email"`
+	EmailVerified bool      `json:"email_verified" db:"email_verified"`
+	LinkedAt      time.Time `json:"linked_at" db:"linked_at"`
 }
 
-// Session represents a user session
+// Session represents a user session. Token holds the session ID carried in
+// the JWT's jti claim, not the JWT itself.
 type Session struct {
 	ID        int       `json:"id" db:"id"`
 	UserID    int       `json:"user_id" db:"user_id"`
-	Token     string    `json:"token" db:"token"`
+	Token     string    `json:"-" db:"token"`
 	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
-// GoogleUserInfo represents user information from Google OAuth
-type GoogleUserInfo struct {
-	ID      string `json:"id"`
-	Email   string `json:"email"`
-	Name    string `json:"name"`
-	Picture string `json:"picture"`
+// Role is a named set of permissions that can be assigned to users
+type Role struct {
+	ID          int       `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// APIToken represents a personal access token. The secret is only returned once, on creation.
+type APIToken struct {
+	ID         int        `json:"id" db:"id"`
+	UserID     int        `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ExternalIdentity represents a user as asserted by an identity provider
+type ExternalIdentity struct {
+	Provider      string `json:"provider"`
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+}
+
+// legacyExternalID is the value written to the legacy users.google_id column,
+// which identities replaced but older databases still require to be unique.
+func (i *ExternalIdentity) legacyExternalID() string {
+	if i.Provider == "google" {
+		return i.Subject
+	}
+	return i.Provider + ":" + i.Subject
 }
 
-// JWTClaims represents the claims in a JWT token
+// JWTClaims represents the claims in a JWT token. Requests authenticated with an
+// API token carry the token's ID and scopes instead of a session ID, and those
+// authenticated with a client certificate carry the certificate identity.
 type JWTClaims struct {
-	UserID int    `json:"user_id"`
-	Email  string `json:"email"`
-	Name   string `json:"name"`
+	UserID    int      `json:"user_id"`
+	Email     string   `json:"email"`
+	Name      string   `json:"name"`
+	SessionID string   `json:"jti"`
+	Roles     []string `json:"roles"`
+	TokenID   int      `json:"-"`
+	Scopes    []string `json:"-"`
+	Principal string   `json:"-"`
 }
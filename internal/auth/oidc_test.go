@@ -0,0 +1,328 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testClientID = "test-client"
+
+// fakeIssuer is an OpenID Connect provider serving discovery, JWKS and token endpoints.
+// The token endpoint returns the ID token set by the test.
+type fakeIssuer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    map[string]*ecdsa.PrivateKey
+	idToken string
+
+	jwksFetches atomic.Int32
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+	issuer := &fakeIssuer{keys: make(map[string]*ecdsa.PrivateKey)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, oidcMetadata{
+			Issuer:                issuer.URL,
+			AuthorizationEndpoint: issuer.URL + "/authorize",
+			TokenEndpoint:         issuer.URL + "/token",
+			JWKSURI:               issuer.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		issuer.jwksFetches.Add(1)
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+
+		var set JSONWebKeySet
+		for kid, key := range issuer.keys {
+			set.Keys = append(set.Keys, JSONWebKey{
+				Kty: "EC",
+				Kid: kid,
+				Use: "sig",
+				Crv: "P-256",
+				X:   base64.RawURLEncoding.EncodeToString(key.PublicKey.X.FillBytes(make([]byte, 32))),
+				Y:   base64.RawURLEncoding.EncodeToString(key.PublicKey.Y.FillBytes(make([]byte, 32))),
+			})
+		}
+		writeJSON(w, set)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		// PKCE: the verifier from the state must be sent with the code
+		if r.FormValue("code") != "valid-code" || r.FormValue("code_verifier") == "" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+		writeJSON(w, map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     issuer.idToken,
+		})
+	})
+
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+// addKey publishes a new signing key in the JWKS
+func (f *fakeIssuer) addKey(t *testing.T, kid string) {
+	t.Helper()
+	key := newECKey(t)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys[kid] = key
+}
+
+// sign creates an ID token with the key kid; unpublished key IDs get a fresh key
+func (f *fakeIssuer) sign(t *testing.T, kid string, claims IDTokenClaims) string {
+	t.Helper()
+	f.mu.Lock()
+	key, ok := f.keys[kid]
+	f.mu.Unlock()
+	if !ok {
+		key = newECKey(t)
+	}
+	return signIDToken(t, key, kid, claims)
+}
+
+func newECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func signIDToken(t *testing.T, key *ecdsa.PrivateKey, kid string, claims IDTokenClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// claims returns valid ID token claims for this issuer
+func (f *fakeIssuer) claims(nonce string) IDTokenClaims {
+	return IDTokenClaims{
+		Subject:       "user-123",
+		Email:         "user@example.com",
+		EmailVerified: true,
+		Name:          "Test User",
+		Nonce:         nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    f.URL,
+			Audience:  jwt.ClaimStrings{testClientID},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+// exchange runs the callback half of a login against the issuer with idToken
+func (f *fakeIssuer) exchange(t *testing.T, p *oidcProvider, idToken, nonce string) (*ExternalIdentity, error) {
+	t.Helper()
+	f.mu.Lock()
+	f.idToken = idToken
+	f.mu.Unlock()
+	return p.Exchange(context.Background(), "valid-code", &OAuthState{Nonce: nonce, CodeVerifier: "verifier"})
+}
+
+func newTestOIDCProvider(f *fakeIssuer) *oidcProvider {
+	return newOIDCProvider("oidc", "Test", f.URL+"/", testClientID, "secret",
+		"http://localhost/auth/oidc/callback", []string{"openid", "email"}, f.Client())
+}
+
+func TestOIDCExchange(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	issuer.addKey(t, "k1")
+
+	const nonce = "expected-nonce"
+	expired := issuer.claims(nonce)
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-2 * time.Minute))
+	noExpiry := issuer.claims(nonce)
+	noExpiry.ExpiresAt = nil
+	wrongAudience := issuer.claims(nonce)
+	wrongAudience.Audience = jwt.ClaimStrings{"other-client"}
+	wrongIssuer := issuer.claims(nonce)
+	wrongIssuer.Issuer = "https://evil.example"
+	wrongNonce := issuer.claims("other-nonce")
+	noSubject := issuer.claims(nonce)
+	noSubject.Subject = ""
+
+	tests := []struct {
+		name    string
+		idToken string
+		nonce   string
+		wantErr string
+	}{
+		{name: "valid", idToken: issuer.sign(t, "k1", issuer.claims(nonce)), nonce: nonce},
+		{name: "wrong nonce", idToken: issuer.sign(t, "k1", wrongNonce), nonce: nonce, wantErr: "nonce mismatch"},
+		{name: "missing nonce in state", idToken: issuer.sign(t, "k1", issuer.claims("")), nonce: "", wantErr: "nonce mismatch"},
+		{name: "wrong audience", idToken: issuer.sign(t, "k1", wrongAudience), nonce: nonce, wantErr: "aud"},
+		{name: "wrong issuer", idToken: issuer.sign(t, "k1", wrongIssuer), nonce: nonce, wantErr: "unexpected issuer"},
+		{name: "expired", idToken: issuer.sign(t, "k1", expired), nonce: nonce, wantErr: "expired"},
+		{name: "no expiry", idToken: issuer.sign(t, "k1", noExpiry), nonce: nonce, wantErr: "exp"},
+		{name: "no subject", idToken: issuer.sign(t, "k1", noSubject), nonce: nonce, wantErr: "missing subject"},
+		{name: "signed with another key", idToken: signIDToken(t, newECKey(t), "k1", issuer.claims(nonce)), nonce: nonce, wantErr: "signature is invalid"},
+		{name: "unsigned", idToken: unsignedToken(t, issuer.claims(nonce)), nonce: nonce, wantErr: "signing method"},
+	}
+
+	p := newTestOIDCProvider(issuer)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := issuer.exchange(t, p, tt.idToken, tt.nonce)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Exchange() error = %v", err)
+				}
+				if identity.Provider != "oidc" || identity.Subject != "user-123" || identity.Email != "user@example.com" || !identity.EmailVerified {
+					t.Errorf("Exchange() = %+v", identity)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Exchange() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeRejectsBadCode(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	issuer.addKey(t, "k1")
+	p := newTestOIDCProvider(issuer)
+
+	_, err := p.Exchange(context.Background(), "stolen-code", &OAuthState{Nonce: "n", CodeVerifier: "verifier"})
+	if err == nil || !strings.Contains(err.Error(), "failed to exchange code") {
+		t.Errorf("Exchange() error = %v, want a failed exchange", err)
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	issuer.addKey(t, "k1")
+	p := newTestOIDCProvider(issuer)
+	const nonce = "expected-nonce"
+
+	if _, err := issuer.exchange(t, p, issuer.sign(t, "k1", issuer.claims(nonce)), nonce); err != nil {
+		t.Fatalf("Exchange() with k1 error = %v", err)
+	}
+	if got := issuer.jwksFetches.Load(); got != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", got)
+	}
+
+	// Known keys come from the cache
+	if _, err := issuer.exchange(t, p, issuer.sign(t, "k1", issuer.claims(nonce)), nonce); err != nil {
+		t.Fatalf("second Exchange() with k1 error = %v", err)
+	}
+	if got := issuer.jwksFetches.Load(); got != 1 {
+		t.Fatalf("JWKS fetched %d times for a cached key, want 1", got)
+	}
+
+	// The provider rotates to k2. Within jwksMinRefresh of the last fetch an unknown
+	// key ID is rejected without refetching, so forged kids can't hammer the provider.
+	issuer.addKey(t, "k2")
+	_, err := issuer.exchange(t, p, issuer.sign(t, "k2", issuer.claims(nonce)), nonce)
+	if err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("Exchange() with k2 error = %v, want unknown signing key", err)
+	}
+	if got := issuer.jwksFetches.Load(); got != 1 {
+		t.Fatalf("JWKS fetched %d times within the refresh throttle, want 1", got)
+	}
+
+	// Once the throttle has passed, the unknown key ID forces a refresh
+	_, _, verifier, err := p.discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier.mu.Lock()
+	verifier.fetchedAt = time.Now().Add(-jwksMinRefresh - time.Second)
+	verifier.mu.Unlock()
+
+	if _, err := issuer.exchange(t, p, issuer.sign(t, "k2", issuer.claims(nonce)), nonce); err != nil {
+		t.Fatalf("Exchange() with k2 after the throttle error = %v", err)
+	}
+	if got := issuer.jwksFetches.Load(); got != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", got)
+	}
+
+	// A key ID the provider never published is still rejected after the refresh
+	_, err = issuer.exchange(t, p, issuer.sign(t, "k3", issuer.claims(nonce)), nonce)
+	if err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("Exchange() with k3 error = %v, want unknown signing key", err)
+	}
+}
+
+func TestOIDCStaleKeysSurviveOutage(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	issuer.addKey(t, "k1")
+	p := newTestOIDCProvider(issuer)
+	const nonce = "expected-nonce"
+
+	idToken := issuer.sign(t, "k1", issuer.claims(nonce))
+	if _, err := issuer.exchange(t, p, idToken, nonce); err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+
+	_, _, verifier, _ := p.discover(context.Background())
+	verifier.mu.Lock()
+	verifier.fetchedAt = time.Now().Add(-jwksCacheTTL - time.Second)
+	verifier.jwksURL = issuer.URL + "/unavailable"
+	verifier.mu.Unlock()
+
+	if _, err := verifier.Verify(context.Background(), idToken, nonce); err != nil {
+		t.Errorf("Verify() with a stale key during an outage error = %v", err)
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	p := newOIDCProvider("oidc", "Test", issuer.URL+"/other", testClientID, "secret",
+		"http://localhost/auth/oidc/callback", nil, issuer.Client())
+
+	// The discovery document is only served at the real issuer's path
+	if _, err := p.AuthCodeURL(context.Background(), &OAuthState{}); err == nil {
+		t.Error("AuthCodeURL() succeeded for a mismatched issuer")
+	}
+}
+
+// unsignedToken encodes claims as an alg=none JWT
+func unsignedToken(t *testing.T, claims IDTokenClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
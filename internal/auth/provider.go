@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"webui-skeleton/internal/config"
+	"webui-skeleton/internal/tracing"
+)
+
+// IdentityProvider is an external login provider using the OAuth2 authorization code flow
+type IdentityProvider interface {
+	// Name is the registry key used in /auth/{provider}/... routes
+	Name() string
+
+	// DisplayName is the human readable name shown on the login page
+	DisplayName() string
+
+	// AuthCodeURL returns the URL the browser is redirected to in order to log in
+	AuthCodeURL(ctx context.Context, st *OAuthState) (string, error)
+
+	// Exchange trades the authorization code for the user's identity
+	Exchange(ctx context.Context, code string, st *OAuthState) (*ExternalIdentity, error)
+}
+
+// ProviderInfo describes an enabled provider for templates and API responses
+type ProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// ProviderRegistry holds the enabled identity providers keyed by name
+type ProviderRegistry struct {
+	providers map[string]IdentityProvider
+	order     []string
+}
+
+// NewProviderRegistry creates an empty provider registry
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{
+		providers: make(map[string]IdentityProvider),
+	}
+}
+
+// Register adds a provider, replacing any provider already registered under the same name
+func (r *ProviderRegistry) Register(p IdentityProvider) {
+	if _, exists := r.providers[p.Name()]; !exists {
+		r.order = append(r.order, p.Name())
+	}
+	r.providers[p.Name()] = p
+}
+
+// Get returns the provider registered under name
+func (r *ProviderRegistry) Get(name string) (IdentityProvider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// List returns the registered providers in registration order
+func (r *ProviderRegistry) List() []ProviderInfo {
+	infos := make([]ProviderInfo, 0, len(r.order))
+	for _, name := range r.order {
+		p := r.providers[name]
+		infos = append(infos, ProviderInfo{Name: p.Name(), DisplayName: p.DisplayName()})
+	}
+	return infos
+}
+
+// providersFromConfig registers every provider enabled in the auth configuration
+func providersFromConfig(cfg *config.AuthConfig) *ProviderRegistry {
+	registry := NewProviderRegistry()
+	// Token exchanges and userinfo requests are traced as children of the callback request
+	client := &http.Client{Timeout: 10 * time.Second, Transport: tracing.Transport(nil)}
+
+	if cfg.GoogleEnabled() {
+		registry.Register(newGoogleProvider(cfg.GoogleClientID, cfg.GoogleClientSecret, cfg.GoogleRedirectURL, client))
+	}
+	if cfg.GitHubEnabled() {
+		registry.Register(newGitHubProvider(cfg.GitHubClientID, cfg.GitHubClientSecret, cfg.GitHubRedirectURL, client))
+	}
+	if cfg.GitLabEnabled() {
+		registry.Register(newGitLabProvider(cfg.GitLabBaseURL, cfg.GitLabClientID, cfg.GitLabClientSecret, cfg.GitLabRedirectURL, client))
+	}
+	if cfg.OIDCEnabled() {
+		registry.Register(newOIDCProvider(cfg.OIDCName, cfg.OIDCDisplayName, cfg.OIDCIssuerURL,
+			cfg.OIDCClientID, cfg.OIDCClientSecret, cfg.OIDCRedirectURL, cfg.OIDCScopes, client))
+	}
+
+	return registry
+}
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// oidcMetadata is the subset of an OpenID Connect discovery document we use
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcProvider is an OpenID Connect provider, either with well-known
+// endpoints (Google) or configured through its discovery document
+type oidcProvider struct {
+	name        string
+	displayName string
+	issuerURL   string
+	issuers     []string
+	client      *http.Client
+
+	// oauth2 config without the endpoint, which may only be known after discovery
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	metadata *oidcMetadata
+	verifier *idTokenVerifier
+}
+
+// newGoogleProvider creates the Google provider using its published endpoints
+func newGoogleProvider(clientID, clientSecret, redirectURL string, client *http.Client) *oidcProvider {
+	p := &oidcProvider{
+		name:         "google",
+		displayName:  "Google",
+		issuers:      []string{"https://accounts.google.com", "accounts.google.com"},
+		client:       client,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       []string{"openid", "email", "profile"},
+	}
+	p.setMetadata(&oidcMetadata{
+		Issuer:                "https://accounts.google.com",
+		AuthorizationEndpoint: google.Endpoint.AuthURL,
+		TokenEndpoint:         google.Endpoint.TokenURL,
+		UserInfoEndpoint:      "https://openidconnect.googleapis.com/v1/userinfo",
+		JWKSURI:               "https://www.googleapis.com/oauth2/v3/certs",
+	})
+	return p
+}
+
+// newOIDCProvider creates a generic OpenID Connect provider; discovery happens on first use
+func newOIDCProvider(name, displayName, issuerURL, clientID, clientSecret, redirectURL string,
+	scopes []string, client *http.Client) *oidcProvider {
+
+	issuerURL = strings.TrimSuffix(issuerURL, "/")
+	return &oidcProvider{
+		name:         name,
+		displayName:  displayName,
+		issuerURL:    issuerURL,
+		issuers:      []string{issuerURL},
+		client:       client,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+	}
+}
+
+func (p *oidcProvider) Name() string        { return p.name }
+func (p *oidcProvider) DisplayName() string { return p.displayName }
+
+// AuthCodeURL returns the authorization URL with the state, nonce and PKCE challenge
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, st *OAuthState) (string, error) {
+	oauth, _, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return oauth.AuthCodeURL(st.State,
+		oauth2.AccessTypeOffline,
+		oauth2.S256ChallengeOption(st.CodeVerifier),
+		oauth2.SetAuthURLParam("nonce", st.Nonce),
+	), nil
+}
+
+// Exchange redeems the code and verifies the returned ID token.
+// The userinfo endpoint is only called when the token lacks the profile claims we need.
+func (p *oidcProvider) Exchange(ctx context.Context, code string, st *OAuthState) (*ExternalIdentity, error) {
+	oauth, metadata, verifier, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(st.CodeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, fmt.Errorf("token response did not include an id_token")
+	}
+
+	claims, err := verifier.Verify(ctx, rawIDToken, st.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if (claims.Email == "" || claims.Name == "") && metadata.UserInfoEndpoint != "" {
+		profile, err := p.fetchUserInfo(ctx, oauth, token, metadata.UserInfoEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		if profile.Subject != claims.Subject {
+			return nil, fmt.Errorf("user info subject does not match id token")
+		}
+		claims.Email = profile.Email
+		claims.EmailVerified = profile.EmailVerified
+		claims.Name = profile.Name
+		claims.Picture = profile.Picture
+	}
+
+	return &ExternalIdentity{
+		Provider:      p.name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+// fetchUserInfo loads the user's profile from the userinfo endpoint
+func (p *oidcProvider) fetchUserInfo(ctx context.Context, oauth *oauth2.Config, token *oauth2.Token,
+	endpoint string) (*IDTokenClaims, error) {
+
+	var profile IDTokenClaims
+	if err := getJSON(ctx, oauth.Client(ctx, token), endpoint, &profile); err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
+	}
+	return &profile, nil
+}
+
+// checkJWKS verifies that the provider's discovery document and signing keys can be fetched
+func (p *oidcProvider) checkJWKS(ctx context.Context) error {
+	_, _, verifier, err := p.discover(ctx)
+	if err != nil {
+		return err
+	}
+	if err := verifier.checkReachable(ctx); err != nil {
+		return fmt.Errorf("%s: %w", p.name, err)
+	}
+	return nil
+}
+
+// discover returns the provider configuration, fetching the discovery document if needed
+func (p *oidcProvider) discover(ctx context.Context) (*oauth2.Config, *oidcMetadata, *idTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.oauth, p.metadata, p.verifier, nil
+	}
+
+	var metadata oidcMetadata
+	if err := getJSON(ctx, p.client, p.issuerURL+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to discover OIDC provider %s: %w", p.name, err)
+	}
+
+	if strings.TrimSuffix(metadata.Issuer, "/") != p.issuerURL {
+		return nil, nil, nil, fmt.Errorf("OIDC provider %s reported issuer %q, expected %q", p.name, metadata.Issuer, p.issuerURL)
+	}
+
+	p.issuers = append(p.issuers, metadata.Issuer)
+	p.setMetadataLocked(&metadata)
+	return p.oauth, p.metadata, p.verifier, nil
+}
+
+func (p *oidcProvider) setMetadata(metadata *oidcMetadata) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.setMetadataLocked(metadata)
+}
+
+func (p *oidcProvider) setMetadataLocked(metadata *oidcMetadata) {
+	p.metadata = metadata
+	p.oauth = &oauth2.Config{
+		ClientID:     p.clientID,
+		ClientSecret: p.clientSecret,
+		RedirectURL:  p.redirectURL,
+		Scopes:       p.scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  metadata.AuthorizationEndpoint,
+			TokenURL: metadata.TokenEndpoint,
+		},
+	}
+	p.verifier = newIDTokenVerifier(p.issuers, p.clientID, metadata.JWKSURI, p.client)
+}
+
+// getJSON performs a GET request and decodes a JSON response into v
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,358 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"webui-skeleton/internal/database"
+)
+
+// Permissions checked by RequirePermission
+const (
+	PermUsersRead   = "users:read"
+	PermUsersWrite  = "users:write"
+	PermRolesRead   = "roles:read"
+	PermRolesWrite  = "roles:write"
+	PermSystemRead  = "system:read"
+	PermSystemWrite = "system:write"
+
+	// PermSystemDebug grants the profiling endpoints, which expose memory contents
+	PermSystemDebug = "system:debug"
+)
+
+// Built-in roles
+const (
+	RoleAdmin  = "admin"
+	RoleViewer = "viewer"
+)
+
+// permissionsTTL is how long role permissions are cached before being reloaded
+const permissionsTTL = 30 * time.Second
+
+var (
+	ErrRoleNotFound    = errors.New("role not found")
+	ErrRoleNotAssigned = errors.New("role not assigned to user")
+	ErrLastAdmin       = errors.New("cannot remove the last admin")
+)
+
+// builtinRoles are created on startup; missing permissions are added to them on upgrade
+var builtinRoles = []Role{
+	{
+		Name:        RoleAdmin,
+		Description: "Full access to the admin area",
+		Permissions: []string{PermUsersRead, PermUsersWrite, PermRolesRead, PermRolesWrite, PermSystemRead, PermSystemWrite, PermSystemDebug},
+	},
+	{
+		Name:        RoleViewer,
+		Description: "Read-only access to the admin area",
+		Permissions: []string{PermUsersRead, PermRolesRead, PermSystemRead},
+	},
+}
+
+// permissionCache holds the permissions of every role, reloaded after permissionsTTL
+type permissionCache struct {
+	mu       sync.RWMutex
+	roles    map[string][]string
+	loadedAt time.Time
+}
+
+// ensureBuiltinRoles creates the built-in roles and grants them any permissions they lack
+func (s *Service) ensureBuiltinRoles(ctx context.Context) error {
+	for _, role := range builtinRoles {
+		if _, err := s.db.ExecContext(ctx, `
+			INSERT INTO roles (name, description) VALUES (?, ?)`+s.db.Dialect().Upsert([]string{"name"}),
+			role.Name, role.Description); err != nil {
+			return fmt.Errorf("failed to create role %s: %w", role.Name, err)
+		}
+
+		for _, permission := range role.Permissions {
+			if _, err := s.db.ExecContext(ctx, `
+				INSERT INTO role_permissions (role_id, permission)
+				SELECT id, ? FROM roles WHERE name = ?`+s.db.Dialect().Upsert([]string{"role_id", "permission"}),
+				permission, role.Name); err != nil {
+				return fmt.Errorf("failed to grant %s to role %s: %w", permission, role.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ListRoles returns every role with its permissions
+func (s *Service) ListRoles(ctx context.Context) ([]Role, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.id, r.name, r.description, r.created_at, COALESCE(rp.permission, '')
+		FROM roles r LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		ORDER BY r.name, rp.permission`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []Role{}
+	for rows.Next() {
+		var role Role
+		var permission string
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, &permission); err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+
+		if n := len(roles); n == 0 || roles[n-1].ID != role.ID {
+			role.Permissions = []string{}
+			roles = append(roles, role)
+		}
+		if permission != "" {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, permission)
+		}
+	}
+
+	return roles, rows.Err()
+}
+
+// GetUserRoles returns the names of the roles assigned to a user
+func (s *Service) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.name FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = ? ORDER BY r.name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("failed to scan user role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// AssignRole grants a role to a user; assigning a role the user already has is a no-op
+func (s *Service) AssignRole(ctx context.Context, userID int, role string) error {
+	if _, err := s.GetUserByID(ctx, userID); err != nil {
+		return err
+	}
+
+	roleID, err := s.getRoleID(ctx, role)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_roles (user_id, role_id) VALUES (?, ?)`+s.db.Dialect().Upsert([]string{"user_id", "role_id"}),
+		userID, roleID); err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveRole takes a role away from a user. The last admin can't lose the admin role.
+func (s *Service) RemoveRole(ctx context.Context, userID int, role string) error {
+	roleID, err := s.getRoleID(ctx, role)
+	if err != nil {
+		return err
+	}
+
+	remove := func(exec execer) error {
+		result, err := exec.ExecContext(ctx, `DELETE FROM user_roles WHERE user_id = ? AND role_id = ?`, userID, roleID)
+		if err != nil {
+			return fmt.Errorf("failed to remove role: %w", err)
+		}
+
+		if affected, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to remove role: %w", err)
+		} else if affected == 0 {
+			return ErrRoleNotAssigned
+		}
+		return nil
+	}
+
+	if role == RoleAdmin {
+		return s.withoutLosingLastAdmin(ctx, userID, func(tx *database.Tx) error { return remove(tx) })
+	}
+	return remove(s.db)
+}
+
+// withoutLosingLastAdmin runs change in a transaction, failing with ErrLastAdmin instead if
+// userID is the only admin who can still log in. The transaction first writes to the admin
+// role's row, which locks it in PostgreSQL and takes the write lock in SQLite, so that
+// concurrent demotions are checked one after the other and can't both pass.
+func (s *Service) withoutLosingLastAdmin(ctx context.Context, userID int, change func(tx *database.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE roles SET name = name WHERE name = ?`, RoleAdmin); err != nil {
+		return fmt.Errorf("failed to lock the admin role: %w", err)
+	}
+
+	var isAdmin, otherAdmins int
+	if err := tx.QueryRow(`
+		SELECT
+			COUNT(CASE WHEN u.id = ? THEN 1 END),
+			COUNT(CASE WHEN u.id <> ? THEN 1 END)
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		JOIN users u ON u.id = ur.user_id
+		WHERE r.name = ? AND u.disabled_at IS NULL AND u.deleted_at IS NULL`,
+		userID, userID, RoleAdmin).Scan(&isAdmin, &otherAdmins); err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if isAdmin > 0 && otherAdmins == 0 {
+		return ErrLastAdmin
+	}
+
+	if err := change(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// HasPermission reports whether any of the roles grants permission
+func (s *Service) HasPermission(ctx context.Context, roles []string, permission string) (bool, error) {
+	granted, err := s.rolePermissions(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, role := range roles {
+		if slices.Contains(granted[role], permission) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RequirePermission returns a Gin middleware that only lets through users whose roles
+// grant permission. It must run after Middleware().
+func (s *Service) RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, _, exists := GetUserFromContext(c); !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			c.Abort()
+			return
+		}
+
+		roles, _ := GetRolesFromContext(c)
+		allowed, err := s.HasPermission(c.Request.Context(), roles, permission)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			c.Abort()
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions", "code": "forbidden", "permission": permission})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// GetRolesFromContext retrieves the current user's roles from the Gin context
+func GetRolesFromContext(c *gin.Context) ([]string, bool) {
+	value, exists := c.Get("user_roles")
+	if !exists {
+		return nil, false
+	}
+	roles, ok := value.([]string)
+	return roles, ok
+}
+
+// bootstrapAdmin grants the admin role to a user logging in with one of the configured
+// admin emails, as long as the provider verified that email and no active admin exists.
+// Once there is an admin, roles are managed through the admin API alone, so a role
+// taken away from a listed email isn't granted again at the next login.
+func (s *Service) bootstrapAdmin(ctx context.Context, user *User, identity *ExternalIdentity) error {
+	if !identity.EmailVerified || !s.isAdminEmail(identity.Email) {
+		return nil
+	}
+
+	var admins int
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		JOIN users u ON u.id = ur.user_id
+		WHERE r.name = ? AND u.disabled_at IS NULL AND u.deleted_at IS NULL`,
+		RoleAdmin).Scan(&admins); err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	return s.AssignRole(ctx, user.ID, RoleAdmin)
+}
+
+// isAdminEmail reports whether email is one of the configured admin emails
+func (s *Service) isAdminEmail(email string) bool {
+	s.liveMu.RLock()
+	defer s.liveMu.RUnlock()
+	for _, admin := range s.adminEmails {
+		if strings.EqualFold(strings.TrimSpace(admin), email) {
+			return true
+		}
+	}
+	return false
+}
+
+// getRoleID returns the ID of the named role
+func (s *Service) getRoleID(ctx context.Context, role string) (int, error) {
+	var roleID int
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM roles WHERE name = ?`, role).Scan(&roleID)
+	if err == sql.ErrNoRows {
+		return 0, ErrRoleNotFound
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to query role: %w", err)
+	}
+	return roleID, nil
+}
+
+// rolePermissions returns the permissions of every role, reloading them when the cache is stale
+func (s *Service) rolePermissions(ctx context.Context) (map[string][]string, error) {
+	s.permissions.mu.RLock()
+	roles, loadedAt := s.permissions.roles, s.permissions.loadedAt
+	s.permissions.mu.RUnlock()
+
+	if roles != nil && time.Since(loadedAt) < permissionsTTL {
+		return roles, nil
+	}
+
+	list, err := s.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	roles = make(map[string][]string, len(list))
+	for _, role := range list {
+		roles[role.Name] = role.Permissions
+	}
+
+	s.permissions.mu.Lock()
+	s.permissions.roles = roles
+	s.permissions.loadedAt = time.Now()
+	s.permissions.mu.Unlock()
+
+	return roles, nil
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// hasRole reports whether the user currently has role
+func hasRole(t *testing.T, s *Service, userID int, role string) bool {
+	t.Helper()
+	roles, err := s.GetUserRoles(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return slices.Contains(roles, role)
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	s.adminEmails = []string{"root@example.com", "ops@example.com"}
+
+	login := func(email string, verified bool) *User {
+		t.Helper()
+		user, err := s.CreateOrUpdateUser(ctx, &ExternalIdentity{
+			Provider:      "oidc",
+			Subject:       "subject-" + email,
+			Email:         email,
+			EmailVerified: verified,
+			Name:          email,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+
+	if unverified := login("ops@example.com", false); hasRole(t, s, unverified.ID, RoleAdmin) {
+		t.Error("an unverified admin email was granted the admin role")
+	}
+
+	root := login("root@example.com", true)
+	if !hasRole(t, s, root.ID, RoleAdmin) {
+		t.Fatal("the first verified admin email wasn't granted the admin role")
+	}
+
+	// With an admin in place the list no longer grants anything
+	other := newTestUser(t, s, "other@example.com")
+	if err := s.AssignRole(ctx, other.ID, RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveRole(ctx, root.ID, RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	login("root@example.com", true)
+	if hasRole(t, s, root.ID, RoleAdmin) {
+		t.Error("the removed admin role was granted again at the next login")
+	}
+
+	// Once no admin can log in, the list bootstraps one again
+	if _, err := s.db.Exec(`UPDATE users SET disabled_at = CURRENT_TIMESTAMP WHERE id = ?`, other.ID); err != nil {
+		t.Fatal(err)
+	}
+	login("root@example.com", true)
+	if !hasRole(t, s, root.ID, RoleAdmin) {
+		t.Error("admin role not granted while no active admin exists")
+	}
+}
+
+func TestLastAdmin(t *testing.T) {
+	ctx := context.Background()
+
+	changes := map[string]func(s *Service, userID int) error{
+		"RemoveRole":     func(s *Service, userID int) error { return s.RemoveRole(ctx, userID, RoleAdmin) },
+		"DisableUser":    func(s *Service, userID int) error { return s.DisableUser(ctx, userID) },
+		"SoftDeleteUser": func(s *Service, userID int) error { return s.SoftDeleteUser(ctx, userID) },
+		"DeleteUser":     func(s *Service, userID int) error { return s.DeleteUser(ctx, userID) },
+	}
+
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			s := newTestService(t)
+			admin := newTestUser(t, s, "admin@example.com")
+			if err := s.AssignRole(ctx, admin.ID, RoleAdmin); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := change(s, admin.ID); !errors.Is(err, ErrLastAdmin) {
+				t.Fatalf("%s() of the last admin error = %v, want %v", name, err, ErrLastAdmin)
+			}
+			if !hasRole(t, s, admin.ID, RoleAdmin) {
+				t.Fatal("the last admin lost the admin role")
+			}
+			if user, err := s.GetUserByID(ctx, admin.ID); err != nil || user.Blocked() {
+				t.Fatalf("the last admin = %+v, %v, want an active user", user, err)
+			}
+
+			// With a second admin the change goes through
+			second := newTestUser(t, s, "second@example.com")
+			if err := s.AssignRole(ctx, second.ID, RoleAdmin); err != nil {
+				t.Fatal(err)
+			}
+			if err := change(s, admin.ID); err != nil {
+				t.Errorf("%s() with another admin error = %v", name, err)
+			}
+		})
+	}
+}
+
+func TestLastAdminConcurrentDemotions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	const admins = 4
+	ids := make([]int, admins)
+	for i := range ids {
+		user := newTestUser(t, s, string(rune('a'+i))+"@example.com")
+		if err := s.AssignRole(ctx, user.ID, RoleAdmin); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = user.ID
+	}
+
+	// Every admin is demoted at once; exactly one must remain
+	var wg sync.WaitGroup
+	errs := make([]error, admins)
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.RemoveRole(ctx, id, RoleAdmin)
+		}()
+	}
+	wg.Wait()
+
+	var refused int
+	for _, err := range errs {
+		switch {
+		case errors.Is(err, ErrLastAdmin):
+			refused++
+		case err != nil:
+			t.Errorf("RemoveRole() error = %v", err)
+		}
+	}
+	if refused != 1 {
+		t.Errorf("%d demotions refused, want 1", refused)
+	}
+
+	var remaining int
+	for _, id := range ids {
+		if hasRole(t, s, id, RoleAdmin) {
+			remaining++
+		}
+	}
+	if remaining != 1 {
+		t.Errorf("%d admins remain, want 1", remaining)
+	}
+}
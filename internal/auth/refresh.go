@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"webui-skeleton/internal/metrics"
+)
+
+const (
+	// AccessCookieName holds the short-lived access JWT for the web UI
+	AccessCookieName = "auth_token"
+
+	// RefreshCookieName holds the opaque refresh token for the web UI
+	RefreshCookieName = "refresh_token"
+
+	// refreshReuseGrace tolerates concurrent refreshes with the same token (e.g. two
+	// browser tabs) without treating them as a replay of a stolen token
+	refreshReuseGrace = 10 * time.Second
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrRefreshTokenRaced   = errors.New("refresh token was just rotated")
+)
+
+// TokenPair is an access token together with the refresh token that renews it
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	TokenType        string    `json:"token_type"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// IssueTokens starts a new session for a user and returns its first access and refresh tokens.
+// The session, and with it the refresh token family, lives for the refresh token lifetime.
+func (s *Service) IssueTokens(ctx context.Context, user *User) (*TokenPair, error) {
+	sessionExpiresAt := time.Now().Add(s.refreshExpiresIn)
+	sessionID, err := s.createSession(ctx, user.ID, sessionExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokensForSession(ctx, user, sessionID, sessionExpiresAt)
+}
+
+// RefreshTokens rotates a refresh token: the presented token is consumed and a new pair is issued.
+// Presenting an already consumed token revokes the whole session it belongs to.
+func (s *Service) RefreshTokens(ctx context.Context, refreshToken string) (pair *TokenPair, err error) {
+	defer func() { metrics.RecordTokenRefresh(refreshResult(err)) }()
+
+	var (
+		id        int
+		userID    int
+		sessionID string
+		expiresAt time.Time
+		usedAt    sql.NullTime
+	)
+	err = s.db.QueryRowContext(ctx, `
+		SELECT id, user_id, session_id, expires_at, used_at
+		FROM refresh_tokens WHERE token_hash = ?`,
+		hashToken(refreshToken)).Scan(&id, &userID, &sessionID, &expiresAt, &usedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidRefreshToken
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to query refresh token: %w", err)
+	}
+
+	if usedAt.Valid {
+		return nil, s.handleRefreshReuse(ctx, sessionID, usedAt.Time)
+	}
+
+	if time.Now().After(expiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Consume the token; losing this race means someone else used it first
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`,
+		time.Now().UTC(), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume refresh token: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to consume refresh token: %w", err)
+	} else if affected == 0 {
+		return nil, ErrRefreshTokenRaced
+	}
+
+	// The session may have been revoked since the token was issued
+	if err := s.checkSession(ctx, &JWTClaims{UserID: userID, SessionID: sessionID}); err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Blocked() {
+		return nil, ErrUserDisabled
+	}
+
+	return s.issueTokensForSession(ctx, user, sessionID, expiresAt)
+}
+
+// refreshResult classifies the outcome of a refresh, for metrics
+func refreshResult(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, ErrInvalidRefreshToken):
+		return "invalid"
+	case errors.Is(err, ErrRefreshTokenReused):
+		return "reused"
+	case errors.Is(err, ErrRefreshTokenRaced):
+		return "raced"
+	case errors.Is(err, ErrUserDisabled):
+		return "user_disabled"
+	default:
+		return "error"
+	}
+}
+
+// handleRefreshReuse revokes the token family unless the reuse looks like a benign concurrent refresh
+func (s *Service) handleRefreshReuse(ctx context.Context, sessionID string, usedAt time.Time) error {
+	if time.Since(usedAt) < refreshReuseGrace {
+		return ErrRefreshTokenRaced
+	}
+
+	if err := s.RevokeSession(ctx, sessionID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// issueTokensForSession signs a new access token and stores a new refresh token for an existing session
+func (s *Service) issueTokensForSession(ctx context.Context, user *User, sessionID string, refreshExpiresAt time.Time) (*TokenPair, error) {
+	accessToken, accessExpiresAt, err := s.signAccessToken(ctx, user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomString(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, session_id, token_hash, expires_at)
+		VALUES (?, ?, ?, ?)`,
+		user.ID, sessionID, hashToken(refreshToken), refreshExpiresAt.UTC()); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		TokenType:        "Bearer",
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+// SetAuthCookies stores a token pair in the web UI cookies
+func SetAuthCookies(c *gin.Context, pair *TokenPair) {
+	SetCookie(c, AccessCookieName, pair.AccessToken, int(time.Until(pair.AccessExpiresAt).Seconds()), "/")
+	SetCookie(c, RefreshCookieName, pair.RefreshToken, int(time.Until(pair.RefreshExpiresAt).Seconds()), "/")
+}
+
+// ClearAuthCookies removes the web UI auth cookies
+func ClearAuthCookies(c *gin.Context) {
+	SetCookie(c, AccessCookieName, "", -1, "/")
+	SetCookie(c, RefreshCookieName, "", -1, "/")
+}
+
+// SetCookie sets an HttpOnly cookie, marked Secure when the client connected over HTTPS
+func SetCookie(c *gin.Context, name, value string, maxAge int, path string) {
+	c.SetCookie(name, value, maxAge, path, "", IsHTTPS(c), true)
+}
+
+// IsHTTPS reports whether the client connected over HTTPS, either to this server
+// or to a trusted proxy in front of it
+func IsHTTPS(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetBool("https")
+}
+
+// hashToken returns the SHA-256 of a high-entropy opaque token as hex
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"webui-skeleton/internal/config"
+	"webui-skeleton/internal/database"
+)
+
+// newTestService returns a service backed by a migrated SQLite database in a temporary directory
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	db := database.New(&config.DatabaseConfig{Type: config.SQLite, Database: filepath.Join(t.TempDir(), "auth.db")})
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return newServiceForDB(t, db)
+}
+
+// newServiceForDB returns a service on an already migrated database
+func newServiceForDB(t *testing.T, db *database.DB) *Service {
+	t.Helper()
+	s, err := NewService(db, &config.AuthConfig{
+		JWTSecret:             "test-jwt-secret-that-is-long-enough",
+		JWTAlgorithm:          "HS256",
+		JWTExpiresIn:          15 * time.Minute,
+		JWTIssuer:             "test",
+		RefreshTokenExpiresIn: 24 * time.Hour,
+		SessionSecret:         "test-session-secret",
+		AccountLinking:        config.AccountLinkingNever,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// newTestUser signs up a user through a provider login
+func newTestUser(t *testing.T, s *Service, email string) *User {
+	t.Helper()
+	user, err := s.CreateOrUpdateUser(context.Background(), &ExternalIdentity{
+		Provider:      "oidc",
+		Subject:       "subject-" + email,
+		Email:         email,
+		EmailVerified: true,
+		Name:          email,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// backdateRefreshToken sets a refresh token's timestamp column to age ago
+func backdateRefreshToken(t *testing.T, s *Service, token, column string, age time.Duration) {
+	t.Helper()
+	if _, err := s.db.Exec(`UPDATE refresh_tokens SET `+column+` = ? WHERE token_hash = ?`,
+		time.Now().Add(-age).UTC(), hashToken(token)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshTokensRotation(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	user := newTestUser(t, s, "alice@example.com")
+
+	first, err := s.IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstClaims, err := s.Authenticate(ctx, first.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.RefreshTokens(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshTokens() error = %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("RefreshTokens() returned the presented refresh token, want a new one")
+	}
+	if !second.RefreshExpiresAt.Equal(first.RefreshExpiresAt) {
+		t.Errorf("refresh expiry = %v, want the session's %v", second.RefreshExpiresAt, first.RefreshExpiresAt)
+	}
+
+	claims, err := s.Authenticate(ctx, second.AccessToken)
+	if err != nil {
+		t.Fatalf("Authenticate() with the rotated access token error = %v", err)
+	}
+	if claims.UserID != user.ID || claims.SessionID != firstClaims.SessionID {
+		t.Errorf("rotated claims = user %d session %q, want user %d session %q", claims.UserID, claims.SessionID, user.ID, firstClaims.SessionID)
+	}
+
+	// The new refresh token rotates in turn
+	if _, err := s.RefreshTokens(ctx, second.RefreshToken); err != nil {
+		t.Errorf("RefreshTokens() with the rotated token error = %v", err)
+	}
+
+	if _, err := s.RefreshTokens(ctx, "not-a-refresh-token"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshTokens() with an unknown token error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
+
+func TestRefreshTokensExpired(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	pair, err := s.IssueTokens(ctx, newTestUser(t, s, "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backdateRefreshToken(t, s, pair.RefreshToken, "expires_at", time.Second)
+	if _, err := s.RefreshTokens(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshTokens() error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
+
+func TestRefreshTokensConcurrentReuse(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	pair, err := s.IssueTokens(ctx, newTestUser(t, s, "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Several tabs refresh with the same cookie at once
+	const tabs = 5
+	var wg sync.WaitGroup
+	pairs := make([]*TokenPair, tabs)
+	errs := make([]error, tabs)
+	for i := range tabs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pairs[i], errs[i] = s.RefreshTokens(ctx, pair.RefreshToken)
+		}()
+	}
+	wg.Wait()
+
+	var winner *TokenPair
+	for i, err := range errs {
+		switch {
+		case err == nil && winner == nil:
+			winner = pairs[i]
+		case err == nil:
+			t.Error("more than one concurrent refresh succeeded")
+		case !errors.Is(err, ErrRefreshTokenRaced):
+			t.Errorf("concurrent RefreshTokens() error = %v, want %v", err, ErrRefreshTokenRaced)
+		}
+	}
+	if winner == nil {
+		t.Fatal("no concurrent refresh succeeded")
+	}
+
+	// A late tab within the grace window is told it lost the race; the session survives
+	if _, err := s.RefreshTokens(ctx, pair.RefreshToken); !errors.Is(err, ErrRefreshTokenRaced) {
+		t.Errorf("RefreshTokens() within the grace window error = %v, want %v", err, ErrRefreshTokenRaced)
+	}
+	if _, err := s.Authenticate(ctx, winner.AccessToken); err != nil {
+		t.Errorf("Authenticate() after a raced refresh error = %v, want the session kept", err)
+	}
+	if _, err := s.RefreshTokens(ctx, winner.RefreshToken); err != nil {
+		t.Errorf("RefreshTokens() with the winning token error = %v", err)
+	}
+}
+
+func TestRefreshTokensReuseRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	user := newTestUser(t, s, "alice@example.com")
+
+	stolen, err := s.IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := s.RefreshTokens(ctx, stolen.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying the consumed token after the grace window ends its whole session
+	backdateRefreshToken(t, s, stolen.RefreshToken, "used_at", refreshReuseGrace+time.Second)
+	if _, err := s.RefreshTokens(ctx, stolen.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("RefreshTokens() after the grace window error = %v, want %v", err, ErrRefreshTokenReused)
+	}
+
+	if _, err := s.Authenticate(ctx, rotated.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Authenticate() in the revoked session error = %v, want %v", err, ErrSessionRevoked)
+	}
+	if _, err := s.RefreshTokens(ctx, rotated.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshTokens() in the revoked session error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	// The user's other sessions are untouched
+	if _, err := s.Authenticate(ctx, other.AccessToken); err != nil {
+		t.Errorf("Authenticate() in another session error = %v", err)
+	}
+	if _, err := s.RefreshTokens(ctx, other.RefreshToken); err != nil {
+		t.Errorf("RefreshTokens() in another session error = %v", err)
+	}
+}
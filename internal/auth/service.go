@@ -4,73 +4,167 @@ import (
 	"context"
 	"crypto/rand"
 	"database/sql"
-	"encoding/json"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
+	"webui-skeleton/internal/config"
+	"webui-skeleton/internal/database"
+	"webui-skeleton/internal/metrics"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserDisabled = errors.New("user account is disabled")
 )
 
 type Service struct {
-	db           *sql.DB
-	jwtSecret    []byte
-	jwtExpiresIn time.Duration
-	jwtIssuer    string
-	googleConfig oauth2.Config
+	db               *database.DB
+	keys             *KeyManager
+	jwtExpiresIn     time.Duration
+	refreshExpiresIn time.Duration
+	jwtIssuer        string
+	sessions         *sessionCache
+	permissions      *permissionCache
+
+	// liveMu guards the settings that can change while the server runs
+	liveMu                 sync.RWMutex
+	sessionSecret          []byte
+	previousSessionSecret  []byte
+	sessionSecretRotatedAt time.Time
+	providers              *ProviderRegistry
+	accountLinking         config.AccountLinkingPolicy
+	adminEmails            []string
+	clientCertPrincipals   map[string]string
 }
 
 // NewService creates a new authentication service
-func NewService(db *sql.DB, jwtSecret string, jwtExpiresIn time.Duration, jwtIssuer string,
-	googleClientID, googleClientSecret, googleRedirectURL string) *Service {
-
-	googleConfig := oauth2.Config{
-		ClientID:     googleClientID,
-		ClientSecret: googleClientSecret,
-		RedirectURL:  googleRedirectURL,
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-		},
-		Endpoint: google.Endpoint,
-	}
-
-	return &Service{
-		db:           db,
-		jwtSecret:    []byte(jwtSecret),
-		jwtExpiresIn: jwtExpiresIn,
-		jwtIssuer:    jwtIssuer,
-		googleConfig: googleConfig,
+func NewService(db *database.DB, cfg *config.AuthConfig) (*Service, error) {
+	keys, err := NewKeyManager(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Service{
+		db:                   db,
+		keys:                 keys,
+		jwtExpiresIn:         cfg.JWTExpiresIn,
+		refreshExpiresIn:     cfg.RefreshTokenExpiresIn,
+		jwtIssuer:            cfg.JWTIssuer,
+		sessions:             newSessionCache(),
+		permissions:          &permissionCache{},
+		sessionSecret:        []byte(cfg.SessionSecret),
+		providers:            providersFromConfig(cfg),
+		accountLinking:       cfg.AccountLinking,
+		adminEmails:          cfg.AdminEmails,
+		clientCertPrincipals: parseClientCertPrincipals(cfg.ClientCertPrincipals),
+	}
+
+	if err := s.ensureBuiltinRoles(context.Background()); err != nil {
+		return nil, err
 	}
+
+	return s, nil
+}
+
+// Provider returns the enabled identity provider registered under name
+func (s *Service) Provider(name string) (IdentityProvider, bool) {
+	s.liveMu.RLock()
+	defer s.liveMu.RUnlock()
+	return s.providers.Get(name)
 }
 
-// GenerateJWT generates a JWT token for a user
-func (s *Service) GenerateJWT(user *User) (string, error) {
+// Providers lists the enabled identity providers
+func (s *Service) Providers() []ProviderInfo {
+	s.liveMu.RLock()
+	defer s.liveMu.RUnlock()
+	return s.providers.List()
+}
+
+// CheckJWKS verifies that the signing keys of every OpenID Connect provider can be fetched
+func (s *Service) CheckJWKS(ctx context.Context) error {
+	s.liveMu.RLock()
+	providers := s.providers
+	s.liveMu.RUnlock()
+
+	var errs []error
+	for _, name := range providers.order {
+		if p, ok := providers.providers[name].(*oidcProvider); ok {
+			errs = append(errs, p.checkJWKS(ctx))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// UpdateConfig applies the settings that can change while running: the JWT and
+// session secrets, the identity providers, account linking, admin emails and client
+// certificate principals. Tokens and logins issued with the previous secrets stay valid for the overlap window.
+func (s *Service) UpdateConfig(cfg *config.AuthConfig) {
+	s.keys.SetHMACSecret(cfg.JWTSecret)
+
+	providers := providersFromConfig(cfg)
+
+	s.liveMu.Lock()
+	defer s.liveMu.Unlock()
+	if string(s.sessionSecret) != cfg.SessionSecret {
+		s.previousSessionSecret = s.sessionSecret
+		s.sessionSecret = []byte(cfg.SessionSecret)
+		s.sessionSecretRotatedAt = time.Now()
+	}
+	s.providers = providers
+	s.accountLinking = cfg.AccountLinking
+	s.adminEmails = cfg.AdminEmails
+	s.clientCertPrincipals = parseClientCertPrincipals(cfg.ClientCertPrincipals)
+}
+
+// GenerateJWT starts a new session for a user and generates an access token for it
+// without a refresh token; the session ends when the token expires
+func (s *Service) GenerateJWT(ctx context.Context, user *User) (string, error) {
+	sessionID, err := s.createSession(ctx, user.ID, time.Now().Add(s.jwtExpiresIn))
+	if err != nil {
+		return "", err
+	}
+
+	token, _, err := s.signAccessToken(ctx, user, sessionID)
+	return token, err
+}
+
+// signAccessToken signs a short-lived access JWT for a session
+func (s *Service) signAccessToken(ctx context.Context, user *User, sessionID string) (string, time.Time, error) {
+	roles, err := s.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(s.jwtExpiresIn)
 	claims := jwt.MapClaims{
 		"user_id": user.ID,
 		"email":   user.Email,
 		"name":    user.Name,
-		"exp":     time.Now().Add(s.jwtExpiresIn).Unix(),
+		"roles":   roles,
+		"jti":     sessionID,
+		"exp":     expiresAt.Unix(),
 		"iat":     time.Now().Unix(),
 		"iss":     s.jwtIssuer,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.jwtSecret)
+	signed, err := s.keys.Sign(claims)
+	return signed, expiresAt, err
+}
+
+// JWKS returns the public keys other services can use to verify our tokens
+func (s *Service) JWKS() JSONWebKeySet {
+	return s.keys.JWKS()
 }
 
 // ValidateJWT validates a JWT token and returns the claims
 func (s *Service) ValidateJWT(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return s.jwtSecret, nil
-	})
+	token, err := jwt.Parse(tokenString, s.keys.Keyfunc, jwt.WithValidMethods([]string{s.keys.Algorithm()}))
 
 	if err != nil {
 		return nil, err
@@ -92,144 +186,235 @@ func (s *Service) ValidateJWT(tokenString string) (*JWTClaims, error) {
 			return nil, fmt.Errorf("invalid name in token")
 		}
 
+		sessionID, _ := claims["jti"].(string)
+
+		roles := []string{}
+		if values, ok := claims["roles"].([]interface{}); ok {
+			for _, value := range values {
+				if role, ok := value.(string); ok {
+					roles = append(roles, role)
+				}
+			}
+		}
+
 		return &JWTClaims{
-			UserID: int(userID),
-			Email:  email,
-			Name:   name,
+			UserID:    int(userID),
+			Email:     email,
+			Name:      name,
+			SessionID: sessionID,
+			Roles:     roles,
 		}, nil
 	}
 
 	return nil, fmt.Errorf("invalid token")
 }
 
-// GetGoogleAuthURL returns the Google OAuth authorization URL
-func (s *Service) GetGoogleAuthURL() string {
-	state := s.generateRandomState()
-	return s.googleConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
+// Authenticate validates a JWT token and checks that its session hasn't been revoked
+func (s *Service) Authenticate(ctx context.Context, tokenString string) (*JWTClaims, error) {
+	claims, err := s.ValidateJWT(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkSession(ctx, claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
 }
 
-// ExchangeCodeForToken exchanges an authorization code for user info
-func (s *Service) ExchangeCodeForToken(ctx context.Context, code string) (*GoogleUserInfo, error) {
-	token, err := s.googleConfig.Exchange(ctx, code)
+// CreateOrUpdateUser resolves an external identity to a user, creating the user on first login,
+// and grants the admin role to configured admin emails
+func (s *Service) CreateOrUpdateUser(ctx context.Context, identity *ExternalIdentity) (*User, error) {
+	user, err := s.resolveUser(ctx, identity)
 	if err != nil {
-		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
+		return nil, err
+	}
+
+	if user.Blocked() {
+		return nil, ErrUserDisabled
+	}
+
+	if err := s.bootstrapAdmin(ctx, user, identity); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// resolveUser finds or creates the user behind an external identity.
+// An existing account with the same email is only linked automatically when the
+// account linking policy allows it and the provider asserts the email is verified.
+func (s *Service) resolveUser(ctx context.Context, identity *ExternalIdentity) (*User, error) {
+	// Known identity: refresh the profile and return its user
+	var userID int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?`,
+		identity.Provider, identity.Subject).Scan(&userID)
+
+	if err == nil {
+		if err := s.refreshIdentity(ctx, userID, identity); err != nil {
+			return nil, err
+		}
+		return s.GetUserByID(ctx, userID)
+	} else if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to query identity: %w", err)
+	}
+
+	// Unknown identity for an existing email: link only if the policy allows it
+	existing, err := s.getUserByEmail(ctx, identity.Email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if !s.canAutoLink(identity) {
+			return nil, ErrAccountExists
+		}
+		if err := s.LinkIdentity(ctx, existing.ID, identity); err != nil {
+			return nil, err
+		}
+		if err := s.refreshIdentity(ctx, existing.ID, identity); err != nil {
+			return nil, err
+		}
+		return s.GetUserByID(ctx, existing.ID)
 	}
 
-	client := s.googleConfig.Client(ctx, token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	return s.createUser(ctx, identity)
+}
+
+// createUser creates a new user together with its first identity
+func (s *Service) createUser(ctx context.Context, identity *ExternalIdentity) (*User, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user info: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer resp.Body.Close()
+	defer tx.Rollback()
 
-	body, err := io.ReadAll(resp.Body)
+	// google_id is a legacy column that is still NOT NULL UNIQUE on existing databases
+	userID, err := tx.InsertID(`
+		INSERT INTO users (google_id, email, name, picture) 
+		VALUES (?, ?, ?, ?)`,
+		identity.legacyExternalID(), identity.Email, identity.Name, identity.Picture)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	var userInfo GoogleUserInfo
-	if err := json.Unmarshal(body, &userInfo); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal user info: %w", err)
+	if _, err := tx.Exec(`
+		INSERT INTO user_identities (user_id, provider, subject, email, email_verified) 
+		VALUES (?, ?, ?, ?, ?)`,
+		userID, identity.Provider, identity.Subject, identity.Email, identity.EmailVerified); err != nil {
+		return nil, fmt.Errorf("failed to create identity: %w", err)
 	}
 
-	return &userInfo, nil
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit user: %w", err)
+	}
+
+	return &User{
+		ID:        int(userID),
+		Email:     identity.Email,
+		Name:      identity.Name,
+		Picture:   identity.Picture,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}, nil
 }
 
-// CreateOrUpdateUser creates or updates a user from Google OAuth info
-func (s *Service) CreateOrUpdateUser(userInfo *GoogleUserInfo) (*User, error) {
-	// Check if user exists
-	var user User
-	err := s.db.QueryRow(`
-		SELECT id, google_id, email, name, picture, created_at, updated_at 
-		FROM users WHERE google_id = ? OR email = ?`,
-		userInfo.ID, userInfo.Email).Scan(
-		&user.ID, &user.GoogleID, &user.Email, &user.Name, &user.Picture,
-		&user.CreatedAt, &user.UpdatedAt)
+// refreshIdentity updates the stored identity and user profile after a login
+func (s *Service) refreshIdentity(ctx context.Context, userID int, identity *ExternalIdentity) error {
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE user_identities SET email = ?, email_verified = ? 
+		WHERE provider = ? AND subject = ?`,
+		identity.Email, identity.EmailVerified, identity.Provider, identity.Subject); err != nil {
+		return fmt.Errorf("failed to update identity: %w", err)
+	}
 
-	if err == sql.ErrNoRows {
-		// Create new user
-		result, err := s.db.Exec(`
-			INSERT INTO users (google_id, email, name, picture) 
-			VALUES (?, ?, ?, ?)`,
-			userInfo.ID, userInfo.Email, userInfo.Name, userInfo.Picture)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create user: %w", err)
-		}
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE users SET name = ?, picture = ?, updated_at = CURRENT_TIMESTAMP 
+		WHERE id = ?`,
+		identity.Name, identity.Picture, userID); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
 
-		userID, err := result.LastInsertId()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get user ID: %w", err)
-		}
+	return nil
+}
 
-		user.ID = int(userID)
-		user.GoogleID = userInfo.ID
-		user.Email = userInfo.Email
-		user.Name = userInfo.Name
-		user.Picture = userInfo.Picture
-		user.CreatedAt = time.Now()
-		user.UpdatedAt = time.Now()
+// canAutoLink reports whether identity may be linked to an existing account with the same email
+func (s *Service) canAutoLink(identity *ExternalIdentity) bool {
+	s.liveMu.RLock()
+	defer s.liveMu.RUnlock()
+	return s.accountLinking == config.AccountLinkingVerifiedEmail && identity.EmailVerified
+}
+
+// getUserByEmail returns the user with the given email, or nil if there is none
+func (s *Service) getUserByEmail(ctx context.Context, email string) (*User, error) {
+	user, err := scanUser(s.db.QueryRowContext(ctx, `
+		SELECT `+userColumns+` FROM users WHERE email = ?`, email))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
 	} else if err != nil {
 		return nil, fmt.Errorf("failed to query user: %w", err)
-	} else {
-		// Update existing user
-		_, err := s.db.Exec(`
-			UPDATE users SET name = ?, picture = ?, updated_at = CURRENT_TIMESTAMP 
-			WHERE id = ?`,
-			userInfo.Name, userInfo.Picture, user.ID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to update user: %w", err)
-		}
-
-		user.Name = userInfo.Name
-		user.Picture = userInfo.Picture
-		user.UpdatedAt = time.Now()
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 // GetUserByID retrieves a user by ID
-func (s *Service) GetUserByID(userID int) (*User, error) {
-	var user User
-	err := s.db.QueryRow(`
-		SELECT id, google_id, email, name, picture, created_at, updated_at 
-		FROM users WHERE id = ?`, userID).Scan(
-		&user.ID, &user.GoogleID, &user.Email, &user.Name, &user.Picture,
-		&user.CreatedAt, &user.UpdatedAt)
+func (s *Service) GetUserByID(ctx context.Context, userID int) (*User, error) {
+	user, err := scanUser(s.db.QueryRowContext(ctx, `
+		SELECT `+userColumns+` FROM users WHERE id = ?`, userID))
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("user not found")
+		return nil, ErrUserNotFound
 	} else if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 // Middleware returns a Gin middleware that requires authentication
 func (s *Service) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get token from cookie or Authorization header
-		token := s.getTokenFromRequest(c)
-		if token == "" {
+		// Authenticate from cookie or Authorization header
+		claims, err := s.authenticateRequest(c)
+		switch {
+		case errors.Is(err, ErrNoToken):
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
 			c.Abort()
 			return
+		case errors.Is(err, ErrUserDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled", "code": "account_disabled"})
+			c.Abort()
+			return
+		case errors.Is(err, ErrSessionRevoked), errors.Is(err, ErrRefreshTokenReused):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session revoked or expired"})
+			c.Abort()
+			return
+		case errors.Is(err, ErrCSRFHeaderMissing):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Missing " + CSRFHeader + " header", "code": "csrf_header_missing"})
+			c.Abort()
+			return
+		case errors.Is(err, ErrUnknownPrincipal):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unknown service principal", "code": "unknown_principal"})
+			c.Abort()
+			return
+		case err != nil:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
 		}
 
-		// Validate the token
-		claims, err := s.ValidateJWT(token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		// API tokens are limited to their scopes
+		if !claims.HasScope(ScopeForMethod(c.Request.Method)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient token scope", "code": "insufficient_scope"})
 			c.Abort()
 			return
 		}
 
 		// Set user context
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_name", claims.Name)
-		c.Set("authenticated", true)
+		setUserContext(c, claims)
 
 		c.Next()
 	}
@@ -239,52 +424,124 @@ func (s *Service) Middleware() gin.HandlerFunc {
 // If authenticated, sets user context. If not, continues without blocking.
 func (s *Service) OptionalMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get token from cookie or Authorization header
-		token := s.getTokenFromRequest(c)
-		if token == "" {
-			// No token found, continue without authentication
-			c.Set("authenticated", false)
-			c.Next()
-			return
-		}
-
-		// Validate the token
-		claims, err := s.ValidateJWT(token)
-		if err != nil {
-			// Invalid token, continue without authentication
+		// Authenticate from cookie or Authorization header
+		claims, err := s.authenticateRequest(c)
+		if err != nil || !claims.HasScope(ScopeForMethod(c.Request.Method)) {
+			// No, invalid or insufficiently scoped token, continue without authentication
 			c.Set("authenticated", false)
 			c.Next()
 			return
 		}
 
 		// Valid token, set user context
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_name", claims.Name)
-		c.Set("authenticated", true)
+		setUserContext(c, claims)
 
 		c.Next()
 	}
 }
 
+// authenticateRequest authenticates the request's access token or API token. When the web UI's
+// access cookie has expired or is gone, the refresh cookie is rotated transparently.
+func (s *Service) authenticateRequest(c *gin.Context) (claims *JWTClaims, err error) {
+	ctx := c.Request.Context()
+	defer func() {
+		if err != nil && !errors.Is(err, ErrNoToken) {
+			metrics.RecordTokenValidationFailure(validationFailureReason(err))
+		}
+	}()
+
+	// A verified client certificate mapped to a service takes precedence over tokens
+	if identity, email, ok := s.clientCertPrincipal(c.Request.TLS); ok {
+		return s.authenticateClientCert(ctx, identity, email)
+	}
+
+	token, fromCookie := s.getTokenFromRequest(c)
+	if !fromCookie && IsAPIToken(token) {
+		return s.AuthenticateAPIToken(ctx, token)
+	}
+	// Browsers attach the web UI cookies to requests from other sites too
+	_, refreshErr := c.Cookie(RefreshCookieName)
+	if fromCookie || (token == "" && refreshErr == nil) {
+		if err := checkCSRF(c); err != nil {
+			return nil, err
+		}
+	}
+	if token != "" {
+		claims, err := s.Authenticate(ctx, token)
+		if err == nil || !fromCookie || !errors.Is(err, jwt.ErrTokenExpired) {
+			return claims, err
+		}
+	}
+
+	refreshToken, err := c.Cookie(RefreshCookieName)
+	if err != nil || refreshToken == "" {
+		if token == "" {
+			return nil, ErrNoToken
+		}
+		return nil, jwt.ErrTokenExpired
+	}
+
+	pair, err := s.RefreshTokens(ctx, refreshToken)
+	if err != nil {
+		// A concurrent request already rotated the cookie; leave it alone
+		if !errors.Is(err, ErrRefreshTokenRaced) {
+			ClearAuthCookies(c)
+		}
+		return nil, err
+	}
+
+	SetAuthCookies(c, pair)
+	return s.Authenticate(ctx, pair.AccessToken)
+}
+
+// validationFailureReason classifies why a token was rejected, for metrics
+func validationFailureReason(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "expired"
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "malformed"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return "invalid_signature"
+	case errors.Is(err, ErrSessionRevoked):
+		return "session_revoked"
+	case errors.Is(err, ErrInvalidAPIToken):
+		return "invalid_api_token"
+	case errors.Is(err, ErrUserDisabled):
+		return "user_disabled"
+	case errors.Is(err, ErrUnknownPrincipal):
+		return "unknown_principal"
+	case errors.Is(err, ErrCSRFHeaderMissing):
+		return "csrf_header_missing"
+	case errors.Is(err, ErrInvalidRefreshToken), errors.Is(err, ErrRefreshTokenReused), errors.Is(err, ErrRefreshTokenRaced):
+		return "refresh_failed"
+	default:
+		return "invalid"
+	}
+}
+
 // getTokenFromRequest extracts JWT token from cookie or Authorization header
-func (s *Service) getTokenFromRequest(c *gin.Context) string {
+// and reports whether it came from the web UI cookie
+func (s *Service) getTokenFromRequest(c *gin.Context) (string, bool) {
 	// First, try to get token from cookie (for web UI)
-	if token, err := c.Cookie("auth_token"); err == nil && token != "" {
-		return token
+	if token, err := c.Cookie(AccessCookieName); err == nil && token != "" {
+		return token, true
 	}
 
 	// Second, try to get token from Authorization header (for API)
 	authHeader := c.GetHeader("Authorization")
 	if authHeader != "" && len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-		return authHeader[7:]
+		return authHeader[7:], false
 	}
 
-	return ""
+	return "", false
 }
 
-func (s *Service) generateRandomState() string {
-	b := make([]byte, 32)
-	rand.Read(b)
-	return fmt.Sprintf("%x", b)
+// randomString returns n random bytes encoded as hex
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
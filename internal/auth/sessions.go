@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// sessionCacheTTL bounds how long another replica may keep accepting a revoked session
+	sessionCacheTTL = 30 * time.Second
+
+	// sessionCacheMaxEntries triggers a sweep of stale cache entries
+	sessionCacheMaxEntries = 10000
+)
+
+var (
+	ErrNoToken        = errors.New("authentication required")
+	ErrSessionRevoked = errors.New("session revoked or expired")
+)
+
+// cachedSession is the result of the last database lookup for a session
+type cachedSession struct {
+	userID    int
+	expiresAt time.Time
+	valid     bool
+	checkedAt time.Time
+}
+
+// sessionCache avoids a database round trip per request for recently seen sessions
+type sessionCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedSession
+}
+
+func newSessionCache() *sessionCache {
+	return &sessionCache{entries: make(map[string]cachedSession)}
+}
+
+func (c *sessionCache) get(sessionID string) (cachedSession, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[sessionID]
+	if !ok || time.Since(entry.checkedAt) > sessionCacheTTL {
+		return cachedSession{}, false
+	}
+	return entry, true
+}
+
+func (c *sessionCache) set(sessionID string, entry cachedSession) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= sessionCacheMaxEntries {
+		for id, e := range c.entries {
+			if time.Since(e.checkedAt) > sessionCacheTTL {
+				delete(c.entries, id)
+			}
+		}
+	}
+	c.entries[sessionID] = entry
+}
+
+// revoke marks a session invalid without waiting for the cache entry to expire
+func (c *sessionCache) revoke(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[sessionID] = cachedSession{valid: false, checkedAt: time.Now()}
+}
+
+// revokeUser marks every cached session of a user invalid
+func (c *sessionCache) revokeUser(userID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, e := range c.entries {
+		if e.userID == userID {
+			c.entries[id] = cachedSession{userID: userID, valid: false, checkedAt: time.Now()}
+		}
+	}
+}
+
+// createSession records a new session for a user and returns its ID (the JWT jti)
+func (s *Service) createSession(ctx context.Context, userID int, expiresAt time.Time) (string, error) {
+	sessionID, err := randomString(16)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+
+	// Opportunistically drop this user's expired sessions and refresh tokens
+	if _, err := s.db.ExecContext(ctx, `
+		DELETE FROM sessions WHERE user_id = ? AND expires_at < ?`,
+		userID, time.Now().UTC()); err != nil {
+		return "", fmt.Errorf("failed to purge expired sessions: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		DELETE FROM refresh_tokens WHERE user_id = ? AND expires_at < ?`,
+		userID, time.Now().UTC()); err != nil {
+		return "", fmt.Errorf("failed to purge expired refresh tokens: %w", err)
+	}
+
+	// The token column holds the session ID, never the JWT itself
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO sessions (user_id, token, expires_at)
+		VALUES (?, ?, ?)`,
+		userID, sessionID, expiresAt.UTC()); err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+
+	s.sessions.set(sessionID, cachedSession{userID: userID, expiresAt: expiresAt, valid: true, checkedAt: time.Now()})
+	return sessionID, nil
+}
+
+// checkSession verifies the session behind a token still exists and hasn't expired
+func (s *Service) checkSession(ctx context.Context, claims *JWTClaims) error {
+	if claims.SessionID == "" {
+		return ErrSessionRevoked
+	}
+
+	entry, ok := s.sessions.get(claims.SessionID)
+	if !ok {
+		var userID int
+		var expiresAt time.Time
+		err := s.db.QueryRowContext(ctx, `
+			SELECT user_id, expires_at FROM sessions WHERE token = ?`,
+			claims.SessionID).Scan(&userID, &expiresAt)
+
+		switch {
+		case err == sql.ErrNoRows:
+			entry = cachedSession{valid: false, checkedAt: time.Now()}
+		case err != nil:
+			return fmt.Errorf("failed to query session: %w", err)
+		default:
+			entry = cachedSession{userID: userID, expiresAt: expiresAt, valid: true, checkedAt: time.Now()}
+		}
+		s.sessions.set(claims.SessionID, entry)
+	}
+
+	if !entry.valid || entry.userID != claims.UserID || time.Now().After(entry.expiresAt) {
+		return ErrSessionRevoked
+	}
+
+	return nil
+}
+
+// ListUserSessions returns a user's active sessions, newest first
+func (s *Service) ListUserSessions(ctx context.Context, userID int) ([]Session, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, token, expires_at, created_at
+		FROM sessions WHERE user_id = ? AND expires_at > ? ORDER BY id DESC`,
+		userID, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(&session.ID, &session.UserID, &session.Token, &session.ExpiresAt, &session.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// CountActiveSessions returns the number of sessions that haven't expired
+func (s *Service) CountActiveSessions(ctx context.Context) (int, error) {
+	var count int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sessions WHERE expires_at > ?`, time.Now().UTC()).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count sessions: %w", err)
+	}
+	return count, nil
+}
+
+// RevokeSession ends a single session along with its refresh tokens
+func (s *Service) RevokeSession(ctx context.Context, sessionID string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE session_id = ?`, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE token = ?`, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	s.sessions.revoke(sessionID)
+	return nil
+}
+
+// RevokeUserSessions ends every session of a user and returns how many were revoked
+func (s *Service) RevokeUserSessions(ctx context.Context, userID int) (int64, error) {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE user_id = ?`, userID); err != nil {
+		return 0, fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ?`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	s.sessions.revokeUser(userID)
+	return result.RowsAffected()
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// StateCookieName is the cookie that binds an OAuth state to the browser
+	StateCookieName = "oauth_state"
+
+	// StateTTL is how long a login attempt may take before the state expires
+	StateTTL = 10 * time.Minute
+
+	defaultReturnTo = "/dashboard"
+)
+
+var (
+	ErrStateMissing  = errors.New("oauth state missing")
+	ErrStateMismatch = errors.New("oauth state mismatch")
+	ErrStateExpired  = errors.New("oauth state expired")
+)
+
+// OAuthState is the per-login data stored in the signed state cookie
+type OAuthState struct {
+	Provider     string `json:"p"`
+	State        string `json:"s"`
+	Nonce        string `json:"n"`
+	CodeVerifier string `json:"v"`
+	ReturnTo     string `json:"r"`
+	LinkUserID   int    `json:"l,omitempty"`
+	ExpiresAt    int64  `json:"e"`
+}
+
+// NewOAuthState creates a random state, nonce and PKCE verifier along with
+// the signed cookie value that binds them to the browser. A non-zero linkUserID
+// marks the flow as linking a new identity to that user instead of logging in.
+func (s *Service) NewOAuthState(provider, returnTo string, linkUserID int) (*OAuthState, string, error) {
+	state, err := randomString(32)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate state: %w", err)
+	}
+
+	nonce, err := randomString(16)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	st := &OAuthState{
+		Provider:     provider,
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		ReturnTo:     SanitizeReturnTo(returnTo),
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(StateTTL).Unix(),
+	}
+
+	payload, err := json.Marshal(st)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	s.liveMu.RLock()
+	secret := s.sessionSecret
+	s.liveMu.RUnlock()
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return st, encoded + "." + signState(secret, encoded), nil
+}
+
+// VerifyOAuthState checks the state returned by the provider against the signed cookie
+// and returns the stored login data, including where the user should land after login
+func (s *Service) VerifyOAuthState(cookie, provider, state string) (*OAuthState, error) {
+	if cookie == "" || state == "" {
+		return nil, ErrStateMissing
+	}
+
+	encoded, signature, ok := strings.Cut(cookie, ".")
+	if !ok || !s.validStateSignature(encoded, signature) {
+		return nil, ErrStateMismatch
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrStateMismatch
+	}
+
+	var stored OAuthState
+	if err := json.Unmarshal(payload, &stored); err != nil {
+		return nil, ErrStateMismatch
+	}
+
+	if stored.Provider != provider || subtle.ConstantTimeCompare([]byte(stored.State), []byte(state)) != 1 {
+		return nil, ErrStateMismatch
+	}
+
+	if time.Now().Unix() > stored.ExpiresAt {
+		return nil, ErrStateExpired
+	}
+
+	stored.ReturnTo = SanitizeReturnTo(stored.ReturnTo)
+	return &stored, nil
+}
+
+// SanitizeReturnTo only allows local absolute paths so the login flow can't be used as an open redirect
+func SanitizeReturnTo(returnTo string) string {
+	if returnTo == "" || !strings.HasPrefix(returnTo, "/") ||
+		strings.HasPrefix(returnTo, "//") || strings.HasPrefix(returnTo, "/\\") {
+		return defaultReturnTo
+	}
+
+	// Encoded slashes, as in /%2F%2Fevil, must not turn into a scheme-relative URL once decoded
+	parsed, err := url.Parse(returnTo)
+	if err != nil || parsed.IsAbs() || parsed.Host != "" ||
+		strings.HasPrefix(parsed.Path, "//") || strings.HasPrefix(parsed.Path, "/\\") {
+		return defaultReturnTo
+	}
+
+	return returnTo
+}
+
+// validStateSignature checks a state cookie signature against the session secret and,
+// for logins started just before the secret was rotated, the previous secret
+func (s *Service) validStateSignature(encoded, signature string) bool {
+	s.liveMu.RLock()
+	defer s.liveMu.RUnlock()
+
+	if hmac.Equal([]byte(signature), []byte(signState(s.sessionSecret, encoded))) {
+		return true
+	}
+	return s.previousSessionSecret != nil && time.Since(s.sessionSecretRotatedAt) < StateTTL &&
+		hmac.Equal([]byte(signature), []byte(signState(s.previousSessionSecret, encoded)))
+}
+
+func signState(secret []byte, encoded string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newStateService(secret string) *Service {
+	return &Service{sessionSecret: []byte(secret)}
+}
+
+// signedState returns a cookie for st signed with secret, bypassing NewOAuthState
+func signedState(t *testing.T, secret string, st OAuthState) string {
+	t.Helper()
+	encoded := base64.RawURLEncoding.EncodeToString(mustJSON(t, st))
+	return encoded + "." + signState([]byte(secret), encoded)
+}
+
+func TestVerifyOAuthState(t *testing.T) {
+	const secret = "current-session-secret"
+	valid := OAuthState{
+		Provider:  "google",
+		State:     "expected-state",
+		ReturnTo:  "/settings",
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	}
+	cookie := signedState(t, secret, valid)
+	encoded, signature, _ := strings.Cut(cookie, ".")
+
+	expired := valid
+	expired.ExpiresAt = time.Now().Add(-time.Second).Unix()
+
+	openRedirect := valid
+	openRedirect.ReturnTo = "//evil.example"
+
+	tamperedPayload := valid
+	tamperedPayload.ReturnTo = "/admin"
+	tamperedEncoded := base64.RawURLEncoding.EncodeToString(mustJSON(t, tamperedPayload))
+
+	tests := []struct {
+		name     string
+		cookie   string
+		provider string
+		state    string
+		wantErr  error
+		wantTo   string
+	}{
+		{name: "valid", cookie: cookie, provider: "google", state: "expected-state", wantTo: "/settings"},
+		{name: "missing cookie", cookie: "", provider: "google", state: "expected-state", wantErr: ErrStateMissing},
+		{name: "missing state", cookie: cookie, provider: "google", state: "", wantErr: ErrStateMissing},
+		{name: "wrong state", cookie: cookie, provider: "google", state: "other-state", wantErr: ErrStateMismatch},
+		{name: "wrong provider", cookie: cookie, provider: "github", state: "expected-state", wantErr: ErrStateMismatch},
+		{name: "no signature", cookie: encoded, provider: "google", state: "expected-state", wantErr: ErrStateMismatch},
+		{name: "tampered signature", cookie: encoded + "." + signature[:len(signature)-2] + "AA", provider: "google", state: "expected-state", wantErr: ErrStateMismatch},
+		{name: "tampered payload", cookie: tamperedEncoded + "." + signature, provider: "google", state: "expected-state", wantErr: ErrStateMismatch},
+		{name: "other secret", cookie: signedState(t, "another-secret", valid), provider: "google", state: "expected-state", wantErr: ErrStateMismatch},
+		{name: "not base64", cookie: "!!!." + signState([]byte(secret), "!!!"), provider: "google", state: "expected-state", wantErr: ErrStateMismatch},
+		{name: "expired", cookie: signedState(t, secret, expired), provider: "google", state: "expected-state", wantErr: ErrStateExpired},
+		{name: "stored return path is sanitized", cookie: signedState(t, secret, openRedirect), provider: "google", state: "expected-state", wantTo: defaultReturnTo},
+	}
+
+	s := newStateService(secret)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.VerifyOAuthState(tt.cookie, tt.provider, tt.state)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyOAuthState() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.ReturnTo != tt.wantTo {
+				t.Errorf("ReturnTo = %q, want %q", got.ReturnTo, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestVerifyOAuthStateRoundTrip(t *testing.T) {
+	s := newStateService("current-session-secret")
+	st, cookie, err := s.NewOAuthState("oidc", "/items?page=2", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.VerifyOAuthState(cookie, "oidc", st.State)
+	if err != nil {
+		t.Fatalf("VerifyOAuthState() error = %v", err)
+	}
+	if got.Nonce != st.Nonce || got.CodeVerifier != st.CodeVerifier || got.LinkUserID != 7 || got.ReturnTo != "/items?page=2" {
+		t.Errorf("VerifyOAuthState() = %+v, want %+v", got, st)
+	}
+}
+
+func TestVerifyOAuthStatePreviousSecret(t *testing.T) {
+	tests := []struct {
+		name      string
+		rotatedAt time.Time
+		wantErr   error
+	}{
+		{name: "within the state lifetime", rotatedAt: time.Now().Add(-time.Minute)},
+		{name: "after the state lifetime", rotatedAt: time.Now().Add(-StateTTL - time.Second), wantErr: ErrStateMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStateService("old-session-secret")
+			st, cookie, err := s.NewOAuthState("google", "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Rotate the secret as UpdateConfig does
+			s.previousSessionSecret = s.sessionSecret
+			s.sessionSecret = []byte("new-session-secret")
+			s.sessionSecretRotatedAt = tt.rotatedAt
+
+			if _, err := s.VerifyOAuthState(cookie, "google", st.State); !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyOAuthState() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSanitizeReturnTo(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", defaultReturnTo},
+		{"/", "/"},
+		{"/settings", "/settings"},
+		{"/items?page=2#top", "/items?page=2#top"},
+		{"settings", defaultReturnTo},
+		{"//evil", defaultReturnTo},
+		{"//evil.example/path", defaultReturnTo},
+		{"/\\evil", defaultReturnTo},
+		{"https://evil", defaultReturnTo},
+		{"javascript:alert(1)", defaultReturnTo},
+		{"/%2F%2Fevil", defaultReturnTo},
+		{"/%2F%5Cevil", defaultReturnTo},
+		{"/%zz", defaultReturnTo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := SanitizeReturnTo(tt.input); got != tt.want {
+				t.Errorf("SanitizeReturnTo(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"webui-skeleton/internal/database"
+)
+
+// userColumns are the users columns read into a User, in scanUser order
+const userColumns = `id, email, name, picture, created_at, updated_at, disabled_at, deleted_at`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser reads a User selected with userColumns
+func scanUser(row rowScanner) (*User, error) {
+	var (
+		user       User
+		disabledAt sql.NullTime
+		deletedAt  sql.NullTime
+	)
+	if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Picture,
+		&user.CreatedAt, &user.UpdatedAt, &disabledAt, &deletedAt); err != nil {
+		return nil, err
+	}
+
+	if disabledAt.Valid {
+		user.DisabledAt = &disabledAt.Time
+	}
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	return &user, nil
+}
+
+// GetUserByEmail retrieves a user by email
+func (s *Service) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	user, err := s.getUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// CreateUser provisions a user ahead of their first login, e.g. to grant roles up front.
+// With an identity (its provider and subject) the user can log in with it right away.
+// Without one, logging in with a verified email links an identity only when the
+// account linking policy allows it.
+func (s *Service) CreateUser(ctx context.Context, email, name string, identity *ExternalIdentity) (*User, error) {
+	existing, err := s.getUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrAccountExists
+	}
+
+	if identity != nil {
+		var ownerID int
+		err := s.db.QueryRowContext(ctx, `
+			SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?`,
+			identity.Provider, identity.Subject).Scan(&ownerID)
+		if err == nil {
+			return nil, ErrIdentityLinked
+		} else if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to query identity: %w", err)
+		}
+
+		linked := *identity
+		linked.Email, linked.Name = email, name
+		return s.createUser(ctx, &linked)
+	}
+
+	// google_id is a legacy column that is still NOT NULL UNIQUE on existing databases
+	userID, err := s.db.InsertIDContext(ctx, `
+		INSERT INTO users (google_id, email, name, picture) 
+		VALUES (?, ?, ?, '')`,
+		"provisioned:"+email, email, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return s.GetUserByID(ctx, int(userID))
+}
+
+// UserFilter selects a page of users for the admin listing
+type UserFilter struct {
+	Query          string
+	IncludeDeleted bool
+	Page           int
+	PerPage        int
+}
+
+// ListUsers returns a page of users matching the filter, newest first, and the total number of matches
+func (s *Service) ListUsers(ctx context.Context, filter UserFilter) ([]User, int, error) {
+	where := []string{}
+	args := []interface{}{}
+
+	if !filter.IncludeDeleted {
+		where = append(where, "deleted_at IS NULL")
+	}
+	if query := strings.TrimSpace(filter.Query); query != "" {
+		pattern := "%" + escapeLike(strings.ToLower(query)) + "%"
+		where = append(where, `(LOWER(email) LIKE ? ESCAPE '\' OR LOWER(name) LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+
+	conditions := ""
+	if len(where) > 0 {
+		conditions = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+conditions, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users`+conditions+` ORDER BY id DESC LIMIT ? OFFSET ?`,
+		append(args, filter.PerPage, (filter.Page-1)*filter.PerPage)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, *user)
+	}
+
+	return users, total, rows.Err()
+}
+
+// DisableUser blocks a user from logging in and ends their sessions.
+// Their API tokens are kept but rejected while the user is disabled.
+func (s *Service) DisableUser(ctx context.Context, userID int) error {
+	err := s.withoutLosingLastAdmin(ctx, userID, func(tx *database.Tx) error {
+		return setUserTimestamp(ctx, tx, userID, "disabled_at", time.Now().UTC())
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.RevokeUserSessions(ctx, userID)
+	return err
+}
+
+// EnableUser lifts a previous DisableUser
+func (s *Service) EnableUser(ctx context.Context, userID int) error {
+	return setUserTimestamp(ctx, s.db, userID, "disabled_at", nil)
+}
+
+// SoftDeleteUser hides a user from the listing and blocks them like DisableUser; RestoreUser undoes it
+func (s *Service) SoftDeleteUser(ctx context.Context, userID int) error {
+	err := s.withoutLosingLastAdmin(ctx, userID, func(tx *database.Tx) error {
+		return setUserTimestamp(ctx, tx, userID, "deleted_at", time.Now().UTC())
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.RevokeUserSessions(ctx, userID)
+	return err
+}
+
+// RestoreUser undoes SoftDeleteUser
+func (s *Service) RestoreUser(ctx context.Context, userID int) error {
+	return setUserTimestamp(ctx, s.db, userID, "deleted_at", nil)
+}
+
+// DeleteUser permanently removes a user and everything that belongs to them
+func (s *Service) DeleteUser(ctx context.Context, userID int) error {
+	if _, err := s.GetUserByID(ctx, userID); err != nil {
+		return err
+	}
+
+	err := s.withoutLosingLastAdmin(ctx, userID, func(tx *database.Tx) error {
+		// Foreign keys aren't enforced on every SQLite connection, so don't rely on ON DELETE CASCADE
+		for _, table := range []string{"refresh_tokens", "sessions", "api_tokens", "user_roles", "user_identities"} {
+			if _, err := tx.Exec(`DELETE FROM `+table+` WHERE user_id = ?`, userID); err != nil {
+				return fmt.Errorf("failed to delete user's %s: %w", table, err)
+			}
+		}
+		if _, err := tx.Exec(`DELETE FROM users WHERE id = ?`, userID); err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.sessions.revokeUser(userID)
+	return nil
+}
+
+// execer is implemented by *database.DB and *database.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// setUserTimestamp sets or clears (value nil) one of the users status columns
+func setUserTimestamp(ctx context.Context, exec execer, userID int, column string, value interface{}) error {
+	result, err := exec.ExecContext(ctx, `
+		UPDATE users SET `+column+` = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		value, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	} else if affected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// escapeLike escapes the LIKE wildcards in s for use with ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCreateUserLogin(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t) // account linking is "never"
+
+	login := func(subject, email string) (*User, error) {
+		return s.CreateOrUpdateUser(ctx, &ExternalIdentity{
+			Provider:      "oidc",
+			Subject:       subject,
+			Email:         email,
+			EmailVerified: true,
+			Name:          "Logged In",
+		})
+	}
+
+	// Without an identity the account can't be reached by email alone
+	if _, err := s.CreateUser(ctx, "unlinked@example.com", "Unlinked", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := login("unlinked-subject", "unlinked@example.com"); !errors.Is(err, ErrAccountExists) {
+		t.Errorf("login to an unlinked provisioned user error = %v, want %v", err, ErrAccountExists)
+	}
+
+	// With one, the first login finds the provisioned user
+	created, err := s.CreateUser(ctx, "linked@example.com", "Linked", &ExternalIdentity{Provider: "oidc", Subject: "linked-subject"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := login("linked-subject", "linked@example.com")
+	if err != nil {
+		t.Fatalf("login to a linked provisioned user error = %v", err)
+	}
+	if user.ID != created.ID {
+		t.Errorf("login resolved user %d, want the provisioned user %d", user.ID, created.ID)
+	}
+
+	identities, err := s.ListIdentities(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 1 || identities[0].Provider != "oidc" || identities[0].Subject != "linked-subject" {
+		t.Errorf("identities = %+v, want the oidc identity", identities)
+	}
+
+	if _, err := s.CreateUser(ctx, "other@example.com", "Other", &ExternalIdentity{Provider: "oidc", Subject: "linked-subject"}); !errors.Is(err, ErrIdentityLinked) {
+		t.Errorf("CreateUser() with a linked identity error = %v, want %v", err, ErrIdentityLinked)
+	}
+	if _, err := s.CreateUser(ctx, "linked@example.com", "Again", nil); !errors.Is(err, ErrAccountExists) {
+		t.Errorf("CreateUser() with a taken email error = %v, want %v", err, ErrAccountExists)
+	}
+}
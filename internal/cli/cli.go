@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"webui-skeleton/internal/app"
+	"webui-skeleton/internal/auth"
+	"webui-skeleton/internal/config"
+	"webui-skeleton/internal/database"
+	"webui-skeleton/internal/logger"
+)
+
+const usage = `Usage: webui-be [command] [flags]
+
+Commands:
+  serve                         Start the web server (default)
+  migrate up|down|status        Apply, roll back or list database migrations
+  user create|list|grant-role|disable
+                                Manage users
+  token mint                    Issue an access token for a user, for debugging
+  config check|print            Validate or print the effective configuration
+  config encrypt-secrets|decrypt-secrets
+                                Encrypt or decrypt a secrets file for SECRETS_FILE
+  version                       Print the version and build information
+
+Run "webui-be <command> -h" for the flags of a command.
+`
+
+// errUsage is returned after printing usage for an incomplete or unknown command
+var errUsage = errors.New("invalid usage")
+
+// Run executes the command in args, which exclude the program name.
+// Without a command, or when args start with a flag, it starts the server.
+func Run(args []string, templateFS embed.FS) error {
+	if len(args) == 0 || (strings.HasPrefix(args[0], "-") && !isHelp(args[0])) {
+		return ignoreHelp(runServe(args, templateFS))
+	}
+
+	command, args := args[0], args[1:]
+
+	var err error
+	switch command {
+	case "serve":
+		err = runServe(args, templateFS)
+	case "migrate":
+		err = runMigrate(args)
+	case "user":
+		err = runUser(args)
+	case "token":
+		err = runToken(args)
+	case "config":
+		err = runConfig(args)
+	case "version", "--version":
+		err = runVersion(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		fmt.Fprint(os.Stderr, usage)
+		err = errUsage
+	}
+
+	return ignoreHelp(err)
+}
+
+// runServe starts the web server and blocks until it shuts down
+func runServe(args []string, templateFS embed.FS) error {
+	application := app.New(templateFS)
+	defer application.Cleanup()
+
+	// Initialize all components
+	if err := application.Initialize(args); err != nil {
+		logger.Log.Error().Err(err).Msg("❌ Failed to initialize application")
+		return err
+	}
+
+	// Run the application
+	if err := application.Run(); err != nil {
+		logger.Log.Error().Err(err).Msg("❌ Application failed to run")
+		return err
+	}
+
+	return nil
+}
+
+// subcommand splits args into a subcommand and its arguments, printing usage when it is missing
+func subcommand(args []string, usage string) (string, []string, error) {
+	if len(args) == 0 || isHelp(args[0]) {
+		fmt.Fprintf(os.Stderr, "Usage: %s\n", usage)
+		if len(args) > 0 {
+			return "", nil, flag.ErrHelp
+		}
+		return "", nil, errUsage
+	}
+	return args[0], args[1:], nil
+}
+
+// unknownSubcommand reports a subcommand that doesn't exist
+func unknownSubcommand(command, usage string) error {
+	fmt.Fprintf(os.Stderr, "Usage: %s\n", usage)
+	return fmt.Errorf("unknown subcommand %q", command)
+}
+
+// newFlagSet creates the flag set of a command; usage describes its positional arguments
+func newFlagSet(name, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: webui-be %s\n", usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// loadConfig loads and validates the configuration for a maintenance command.
+// Unless debug is enabled, info logs are suppressed so the output stays scriptable.
+func loadConfig(verbose bool) (*config.Config, error) {
+	cfg, err := config.LoadConfiguration(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	level := cfg.LogLevel
+	if !verbose && !cfg.Debug {
+		level = "warn"
+	}
+	logger.Initialize(cfg.Debug, level)
+
+	return cfg, nil
+}
+
+// openDatabase connects to the configured database
+func openDatabase(cfg *config.Config) (*database.DB, error) {
+	db := database.New(&cfg.Database)
+	if err := db.Connect(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// openAuth connects to the database, applies pending migrations and sets up the auth service
+func openAuth(cfg *config.Config) (*database.DB, *auth.Service, error) {
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := db.Migrate(); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	authSvc, err := auth.NewService(db, &cfg.Auth)
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to set up authentication: %w", err)
+	}
+
+	return db, authSvc, nil
+}
+
+// findUser looks up a user by numeric ID or email
+func findUser(ctx context.Context, authSvc *auth.Service, idOrEmail string) (*auth.User, error) {
+	if id, err := strconv.Atoi(idOrEmail); err == nil {
+		return authSvc.GetUserByID(ctx, id)
+	}
+	return authSvc.GetUserByEmail(ctx, idOrEmail)
+}
+
+// isHelp reports whether arg asks for help
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// ignoreHelp treats an explicit request for help as success
+func ignoreHelp(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"webui-skeleton/internal/config"
+)
+
+const configUsage = `webui-be config check|print [--format yaml|toml|json] [--config FILE] [--host HOST] [--port PORT] [--debug]
+       webui-be config encrypt-secrets|decrypt-secrets --key-file FILE [--generate-key]`
+
+// runConfig validates or prints the effective configuration.
+// It accepts the same flags as serve, so it sees what the server would.
+func runConfig(args []string) error {
+	command, args, err := subcommand(args, configUsage)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "check", "print":
+	case "encrypt-secrets", "decrypt-secrets":
+		return runSecrets(command, args)
+	default:
+		return unknownSubcommand(command, configUsage)
+	}
+
+	// --format is only known to print; everything else is a configuration flag
+	format := "json"
+	if command == "print" {
+		format, args = extractFormat(args, format)
+	}
+
+	if command == "check" {
+		if _, err := config.LoadConfiguration(args); err != nil {
+			return err
+		}
+		fmt.Println("Configuration is valid")
+		return nil
+	}
+
+	cfg, err := config.Load(args)
+	if err != nil {
+		return err
+	}
+
+	return cfg.Redacted().Encode(os.Stdout, format)
+}
+
+// extractFormat removes a --format flag from args and returns its value
+func extractFormat(args []string, format string) (string, []string) {
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimLeft(args[i], "-")
+		switch {
+		case args[i] == arg:
+			rest = append(rest, args[i])
+		case arg == "format" && i+1 < len(args):
+			format = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "format="):
+			format = strings.TrimPrefix(arg, "format=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return format, rest
+}
+
+// runSecrets encrypts or decrypts a secrets file for SECRETS_FILE, from stdin to stdout
+func runSecrets(command string, args []string) error {
+	var keyFile string
+	var generateKey bool
+	flags := newFlagSet("config "+command, "config "+command+" --key-file FILE [--generate-key] < INPUT > OUTPUT\n\n"+
+		"Secrets are in .env format, e.g. JWT_SECRET=... on its own line.")
+	flags.StringVar(&keyFile, "key-file", "", "File with the hex-encoded encryption key (SECRETS_KEY_FILE)")
+	if command == "encrypt-secrets" {
+		flags.BoolVar(&generateKey, "generate-key", false, "Write a new random key to --key-file first")
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if keyFile == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	if generateKey {
+		key, err := config.GenerateSecretsKey()
+		if err != nil {
+			return err
+		}
+		// O_EXCL so an existing key, and the files encrypted with it, are never lost
+		f, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create key file: %w", err)
+		}
+		_, err = fmt.Fprintln(f, key)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write key file: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote a new key to %s\n", keyFile)
+	}
+
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	if len(input) == 0 {
+		return errors.New("no input: pipe the secrets file to stdin")
+	}
+
+	var output []byte
+	if command == "encrypt-secrets" {
+		output, err = config.EncryptSecrets(input, string(key))
+	} else {
+		output, err = config.DecryptSecrets(input, string(key))
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(output)
+	return err
+}
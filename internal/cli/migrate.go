@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+const migrateUsage = "webui-be migrate up|down|status"
+
+// runMigrate applies, rolls back or lists database migrations
+func runMigrate(args []string) error {
+	command, args, err := subcommand(args, migrateUsage)
+	if err != nil {
+		return err
+	}
+
+	var steps int
+	flags := newFlagSet("migrate "+command, "migrate "+command+" [flags]")
+	if command == "down" {
+		flags.IntVar(&steps, "steps", 1, "Number of migrations to roll back")
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(command != "status")
+	if err != nil {
+		return err
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch command {
+	case "up":
+		return db.Migrate()
+	case "down":
+		if steps < 1 {
+			return fmt.Errorf("--steps must be at least 1")
+		}
+		return db.Rollback(steps)
+	case "status":
+		status, err := db.MigrationStatus()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, migration := range status {
+			appliedAt := "pending"
+			if migration.Applied {
+				appliedAt = migration.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", migration.Version, migration.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return unknownSubcommand(command, migrateUsage)
+	}
+}
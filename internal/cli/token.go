@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+const tokenUsage = "webui-be token mint"
+
+// runToken issues tokens for debugging
+func runToken(args []string) error {
+	command, args, err := subcommand(args, tokenUsage)
+	if err != nil {
+		return err
+	}
+
+	if command != "mint" {
+		return unknownSubcommand(command, tokenUsage)
+	}
+
+	var expiresIn time.Duration
+	flags := newFlagSet("token mint", "token mint [--expires-in DURATION] USER\n\nUSER is a user ID or email. The access token is printed to stdout.")
+	flags.DurationVar(&expiresIn, "expires-in", 0, "Token lifetime (defaults to JWT_EXPIRES_IN)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+
+	cfg, err := loadConfig(false)
+	if err != nil {
+		return err
+	}
+	if expiresIn > 0 {
+		cfg.Auth.JWTExpiresIn = expiresIn
+	}
+
+	// Generated keys only live in this process, so the server couldn't verify the token
+	if cfg.Auth.JWTAlgorithm != "HS256" && len(cfg.Auth.JWTPrivateKeyFiles) == 0 {
+		return fmt.Errorf("cannot mint tokens with generated %s keys; configure JWT_PRIVATE_KEY_FILES", cfg.Auth.JWTAlgorithm)
+	}
+
+	db, authSvc, err := openAuth(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	user, err := findUser(ctx, authSvc, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if user.Blocked() {
+		return fmt.Errorf("user %d is disabled or deleted", user.ID)
+	}
+
+	// The token starts a real session, so it can be revoked like any login
+	token, err := authSvc.GenerateJWT(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Access token for user %d (%s), valid for %s\n", user.ID, user.Email, cfg.Auth.JWTExpiresIn)
+	fmt.Println(token)
+	return nil
+}
@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"webui-skeleton/internal/auth"
+	"webui-skeleton/internal/config"
+)
+
+const userUsage = "webui-be user create|list|grant-role|disable"
+
+// runUser manages users
+func runUser(args []string) error {
+	command, args, err := subcommand(args, userUsage)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "create":
+		return runUserCreate(args)
+	case "list":
+		return runUserList(args)
+	case "grant-role":
+		return runUserGrantRole(args)
+	case "disable":
+		return runUserDisable(args)
+	default:
+		return unknownSubcommand(command, userUsage)
+	}
+}
+
+// runUserCreate provisions a user, optionally with a role
+func runUserCreate(args []string) error {
+	var email, name, role, provider, subject string
+	flags := newFlagSet("user create", "user create --email EMAIL [--name NAME] [--role ROLE] [--provider PROVIDER --subject SUBJECT]")
+	flags.StringVar(&email, "email", "", "Email address the user logs in with (required)")
+	flags.StringVar(&name, "name", "", "Display name (defaults to the email)")
+	flags.StringVar(&role, "role", "", "Role to grant, e.g. admin")
+	flags.StringVar(&provider, "provider", "", "Identity provider to link, e.g. google (needed with ACCOUNT_LINKING=never)")
+	flags.StringVar(&subject, "subject", "", "The user's ID at --provider, e.g. the OpenID Connect sub claim")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if email == "" {
+		flags.Usage()
+		return fmt.Errorf("--email is required")
+	}
+	if (provider == "") != (subject == "") {
+		flags.Usage()
+		return fmt.Errorf("--provider and --subject must be given together")
+	}
+	if name == "" {
+		name = email
+	}
+
+	cfg, err := loadConfig(false)
+	if err != nil {
+		return err
+	}
+	db, authSvc, err := openAuth(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	var identity *auth.ExternalIdentity
+	if provider != "" {
+		if _, ok := authSvc.Provider(provider); !ok {
+			return fmt.Errorf("unknown or disabled identity provider %q", provider)
+		}
+		identity = &auth.ExternalIdentity{Provider: provider, Subject: subject}
+	}
+
+	user, err := authSvc.CreateUser(ctx, email, name, identity)
+	if err != nil {
+		return err
+	}
+
+	if role != "" {
+		if err := authSvc.AssignRole(ctx, user.ID, role); err != nil {
+			return fmt.Errorf("created user %d but failed to grant role %s: %w", user.ID, role, err)
+		}
+	}
+
+	fmt.Printf("Created user %d (%s)\n", user.ID, user.Email)
+	if identity == nil && cfg.Auth.AccountLinking != config.AccountLinkingVerifiedEmail {
+		fmt.Fprintf(os.Stderr, "Warning: with ACCOUNT_LINKING=%s the user can't log in until an identity is linked; pass --provider and --subject\n", cfg.Auth.AccountLinking)
+	}
+	return nil
+}
+
+// runUserList prints users matching an optional search
+func runUserList(args []string) error {
+	var filter auth.UserFilter
+	flags := newFlagSet("user list", "user list [--query TEXT] [--include-deleted]")
+	flags.StringVar(&filter.Query, "query", "", "Only list users whose email or name contains TEXT")
+	flags.BoolVar(&filter.IncludeDeleted, "include-deleted", false, "Include soft-deleted users")
+	flags.IntVar(&filter.PerPage, "limit", 100, "Maximum number of users to list")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	filter.Page = 1
+
+	cfg, err := loadConfig(false)
+	if err != nil {
+		return err
+	}
+	db, authSvc, err := openAuth(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	users, total, err := authSvc.ListUsers(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tNAME\tROLES\tSTATUS\tCREATED")
+	for _, user := range users {
+		roles, err := authSvc.GetUserRoles(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", user.ID, user.Email, user.Name,
+			joinOrDash(roles), userStatus(&user), user.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if total > len(users) {
+		fmt.Fprintf(os.Stderr, "Showing %d of %d users\n", len(users), total)
+	}
+	return nil
+}
+
+// runUserGrantRole assigns a role to a user
+func runUserGrantRole(args []string) error {
+	flags := newFlagSet("user grant-role", "user grant-role USER ROLE\n\nUSER is a user ID or email.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errUsage
+	}
+
+	cfg, err := loadConfig(false)
+	if err != nil {
+		return err
+	}
+	db, authSvc, err := openAuth(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	user, err := findUser(ctx, authSvc, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := authSvc.AssignRole(ctx, user.ID, flags.Arg(1)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Granted role %s to user %d (%s)\n", flags.Arg(1), user.ID, user.Email)
+	return nil
+}
+
+// runUserDisable blocks a user and ends their sessions
+func runUserDisable(args []string) error {
+	flags := newFlagSet("user disable", "user disable USER\n\nUSER is a user ID or email.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+
+	cfg, err := loadConfig(false)
+	if err != nil {
+		return err
+	}
+	db, authSvc, err := openAuth(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	user, err := findUser(ctx, authSvc, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := authSvc.DisableUser(ctx, user.ID); err != nil {
+		return err
+	}
+
+	fmt.Printf("Disabled user %d (%s)\n", user.ID, user.Email)
+	return nil
+}
+
+// userStatus describes whether a user can log in
+func userStatus(user *auth.User) string {
+	switch {
+	case user.DeletedAt != nil:
+		return "deleted"
+	case user.DisabledAt != nil:
+		return "disabled"
+	default:
+		return "active"
+	}
+}
+
+// joinOrDash joins values with commas, or returns "-" when there are none
+func joinOrDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ",")
+}
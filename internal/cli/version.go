@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"webui-skeleton/internal/version"
+)
+
+// runVersion prints the build information of the binary
+func runVersion(args []string) error {
+	var asJSON, deps bool
+	flags := newFlagSet("version", "version [--json] [--deps]")
+	flags.BoolVar(&asJSON, "json", false, "Print as JSON, including the module dependencies")
+	flags.BoolVar(&deps, "deps", false, "List the module dependencies")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	info := version.Get()
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+
+	fmt.Printf("webui-be %s\n", info.Version)
+	fmt.Printf("  commit:     %s\n", info.Commit)
+	fmt.Printf("  dirty:      %t\n", info.Dirty)
+	if info.CommitTime != "" {
+		fmt.Printf("  committed:  %s\n", info.CommitTime)
+	}
+	if info.BuildTime != "" {
+		fmt.Printf("  built:      %s\n", info.BuildTime)
+	}
+	fmt.Printf("  go version: %s\n", info.GoVersion)
+
+	if deps {
+		fmt.Println("  dependencies:")
+		for _, dep := range info.Dependencies {
+			line := fmt.Sprintf("    %s %s", dep.Path, dep.Version)
+			if dep.Replace != "" {
+				line += " => " + dep.Replace
+			}
+			fmt.Println(line)
+		}
+	}
+	return nil
+}
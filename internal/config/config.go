@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -22,14 +23,69 @@ type Config struct {
 	// Authentication configuration
 	Auth AuthConfig `json:"auth"`
 
+	// Secret sources configuration
+	Secrets SecretsConfig `json:"secrets"`
+
+	// Metrics configuration
+	Metrics MetricsConfig `json:"metrics"`
+
+	// Tracing configuration
+	Tracing TracingConfig `json:"tracing"`
+
 	// Logging configuration
 	Debug    bool   `json:"debug"`
 	LogLevel string `json:"log_level"`
+
+	// secretSources are where secrets are read from, kept to re-read rotated secrets
+	secretSources []SecretSource
 }
 
 type ServerConfig struct {
 	Host string `json:"host"`
 	Port int    `json:"port"`
+
+	// ShutdownDelay is how long /readyz fails before the server stops accepting
+	// connections, so that load balancers notice and drain it first
+	ShutdownDelay time.Duration `json:"shutdown_delay"`
+
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies whose
+	// X-Forwarded-For and X-Forwarded-Proto headers are believed; empty trusts none
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	TLS TLSConfig `json:"tls"`
+}
+
+// TLSConfig configures HTTPS on the server; it is served when CertFile and KeyFile are set
+type TLSConfig struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+
+	// MinVersion is 1.2 or 1.3
+	MinVersion string `json:"min_version"`
+
+	// CipherPolicy is default (Go's cipher suites), intermediate (forward-secret AEAD
+	// suites only for TLS 1.2) or modern (TLS 1.3 only)
+	CipherPolicy string `json:"cipher_policy"`
+
+	// ReloadInterval is how often the certificate files are checked for changes,
+	// so that renewed certificates are served without a restart; 0 disables
+	ReloadInterval time.Duration `json:"reload_interval"`
+
+	// ClientCAFile is a PEM bundle of the CAs that issue client certificates. When set,
+	// clients may present a certificate, which must verify against it; see auth.client_cert_principals
+	ClientCAFile string `json:"client_ca_file"`
+
+	// RedirectListen serves redirects from plain HTTP to HTTPS on an address such as :80
+	RedirectListen string `json:"redirect_listen"`
+
+	// HSTSMaxAge is the max-age of the Strict-Transport-Security header sent on HTTPS responses; 0 disables it
+	HSTSMaxAge            time.Duration `json:"hsts_max_age"`
+	HSTSIncludeSubdomains bool          `json:"hsts_include_subdomains"`
+}
+
+// Enabled reports whether the server serves HTTPS
+func (t *TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
 }
 
 type DatabaseConfig struct {
@@ -78,16 +134,161 @@ type AuthConfig struct {
 	JWTExpiresIn time.Duration `json:"jwt_expires_in"`
 	JWTIssuer    string        `json:"jwt_issuer"`
 
+	// JWT signing keys: HS256 uses JWTSecret, RS256/ES256/EdDSA use PEM private keys
+	// (the first file signs) or a generated key rotated every JWTKeyRotationInterval
+	JWTAlgorithm           string        `json:"jwt_algorithm"`
+	JWTPrivateKeyFiles     []string      `json:"jwt_private_key_files"`
+	JWTKeyRotationInterval time.Duration `json:"jwt_key_rotation_interval"`
+	JWTKeyOverlap          time.Duration `json:"jwt_key_overlap"`
+
+	// Refresh tokens outlive access tokens and bound the length of a login session
+	RefreshTokenExpiresIn time.Duration `json:"refresh_token_expires_in"`
+
 	// Google OAuth Configuration
 	GoogleClientID     string `json:"google_client_id"`
 	GoogleClientSecret string `json:"google_client_secret"`
 	GoogleRedirectURL  string `json:"google_redirect_url"`
 
+	// GitHub OAuth Configuration
+	GitHubClientID     string `json:"github_client_id"`
+	GitHubClientSecret string `json:"github_client_secret"`
+	GitHubRedirectURL  string `json:"github_redirect_url"`
+
+	// GitLab OAuth Configuration
+	GitLabBaseURL      string `json:"gitlab_base_url"`
+	GitLabClientID     string `json:"gitlab_client_id"`
+	GitLabClientSecret string `json:"gitlab_client_secret"`
+	GitLabRedirectURL  string `json:"gitlab_redirect_url"`
+
+	// Generic OpenID Connect Configuration
+	OIDCName         string   `json:"oidc_name"`
+	OIDCDisplayName  string   `json:"oidc_display_name"`
+	OIDCIssuerURL    string   `json:"oidc_issuer_url"`
+	OIDCClientID     string   `json:"oidc_client_id"`
+	OIDCClientSecret string   `json:"oidc_client_secret"`
+	OIDCRedirectURL  string   `json:"oidc_redirect_url"`
+	OIDCScopes       []string `json:"oidc_scopes"`
+
 	// Session Configuration
 	SessionSecret string `json:"session_secret"`
 
 	// Auth Settings
-	RequireAuth bool `json:"require_auth"`
+	RequireAuth    bool                 `json:"require_auth"`
+	AccountLinking AccountLinkingPolicy `json:"account_linking"`
+
+	// Users with these verified emails are granted the admin role when they log in
+	AdminEmails []string `json:"admin_emails"`
+
+	// ClientCertPrincipals map client certificate identities to the accounts of
+	// services, as identity=email entries; see ParseClientCertPrincipal
+	ClientCertPrincipals []string `json:"client_cert_principals"`
+}
+
+// Client certificate identity kinds, the prefixes of the identities in auth.client_cert_principals
+const (
+	CertIdentityURI   = "uri"
+	CertIdentityDNS   = "dns"
+	CertIdentityEmail = "email"
+	CertIdentityCN    = "cn"
+)
+
+// ParseClientCertPrincipal splits an auth.client_cert_principals entry such as
+// uri:spiffe://example.org/billing=billing@services.example.org into the
+// certificate identity and the email of the account it authenticates as.
+// The identity is a URI, DNS or email subject alternative name, or the subject common name.
+func ParseClientCertPrincipal(entry string) (identity, email string, err error) {
+	separator := strings.LastIndex(entry, "=")
+	if separator < 0 {
+		return "", "", fmt.Errorf("%q is not an identity=email entry", entry)
+	}
+	identity, email = strings.TrimSpace(entry[:separator]), strings.TrimSpace(entry[separator+1:])
+
+	kind, value, _ := strings.Cut(identity, ":")
+	switch strings.ToLower(kind) {
+	case CertIdentityURI, CertIdentityEmail, CertIdentityCN:
+	case CertIdentityDNS:
+		value = strings.ToLower(value)
+	default:
+		return "", "", fmt.Errorf("%q must start with uri:, dns:, email: or cn:", identity)
+	}
+	if value == "" {
+		return "", "", fmt.Errorf("%q has an empty identity", entry)
+	}
+	if !strings.Contains(email, "@") {
+		return "", "", fmt.Errorf("%q is not an email address", email)
+	}
+	return strings.ToLower(kind) + ":" + value, email, nil
+}
+
+// AccountLinkingPolicy controls whether a new identity is linked to an existing account with the same email
+type AccountLinkingPolicy string
+
+const (
+	// AccountLinkingNever requires users to link additional identities explicitly
+	AccountLinkingNever AccountLinkingPolicy = "never"
+
+	// AccountLinkingVerifiedEmail links automatically when the provider asserts the email is verified
+	AccountLinkingVerifiedEmail AccountLinkingPolicy = "verified_email"
+)
+
+// GoogleEnabled reports whether Google login is configured
+func (a AuthConfig) GoogleEnabled() bool {
+	return a.GoogleClientID != "" && a.GoogleClientSecret != ""
+}
+
+// GitHubEnabled reports whether GitHub login is configured
+func (a AuthConfig) GitHubEnabled() bool {
+	return a.GitHubClientID != "" && a.GitHubClientSecret != ""
+}
+
+// GitLabEnabled reports whether GitLab login is configured
+func (a AuthConfig) GitLabEnabled() bool {
+	return a.GitLabClientID != "" && a.GitLabClientSecret != ""
+}
+
+// OIDCEnabled reports whether the generic OpenID Connect provider is configured
+func (a AuthConfig) OIDCEnabled() bool {
+	return a.OIDCIssuerURL != "" && a.OIDCClientID != ""
+}
+
+// AnyProviderEnabled reports whether at least one identity provider is configured
+func (a AuthConfig) AnyProviderEnabled() bool {
+	return a.GoogleEnabled() || a.GitHubEnabled() || a.GitLabEnabled() || a.OIDCEnabled()
+}
+
+// SecretsConfig configures the encrypted secrets file and how often secrets are re-read
+type SecretsConfig struct {
+	// File is an encrypted .env-format file, created with "webui-be config encrypt-secrets"
+	File    string `json:"file"`
+	KeyFile string `json:"key_file"`
+
+	// RefreshInterval is how often secrets are re-read so rotated values apply without a restart; 0 disables
+	RefreshInterval time.Duration `json:"refresh_interval"`
+}
+
+// MetricsConfig configures the Prometheus metrics endpoint
+type MetricsConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Listen serves /metrics on a separate address such as 127.0.0.1:9090
+	// instead of the main server, to keep it private
+	Listen string `json:"listen"`
+}
+
+// TracingConfig configures OpenTelemetry tracing
+type TracingConfig struct {
+	// Exporter is none, stdout or otlp
+	Exporter string `json:"exporter"`
+
+	// Endpoint is the OTLP/HTTP collector URL, e.g. http://localhost:4318/v1/traces;
+	// empty uses the standard OTEL_EXPORTER_OTLP_* variables
+	Endpoint string `json:"endpoint"`
+
+	// SampleRatio is the fraction of new traces that are recorded; requests that
+	// carry a traceparent follow the caller's sampling decision
+	SampleRatio float64 `json:"sample_ratio"`
+
+	ServiceName string `json:"service_name"`
 }
 
 type DatabaseType string
@@ -97,131 +298,385 @@ const (
 	PostgreSQL DatabaseType = "postgresql"
 )
 
-// LoadConfiguration loads and validates the application configuration
-func LoadConfiguration() (*Config, error) {
-	// Load .env file if it exists
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found or couldn't be loaded: %v", err)
+// LoadConfiguration loads and validates the application configuration.
+// args are the command line flags, without the program name.
+// Every malformed or invalid setting is reported in a single *ValidationError.
+func LoadConfiguration(args []string) (*Config, error) {
+	config, problems, err := load(args)
+	if err != nil {
+		return nil, err
 	}
 
-	config := &Config{}
-
-	// Parse command line flags
-	parseFlags(config)
-
-	// Load environment variables and apply defaults
-	loadEnvironmentVariables(config)
-
 	// Validate configuration
-	if err := validateConfig(config); err != nil {
+	validateConfig(config, problems)
+	if err := problems.err(); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
 	}
 
 	return config, nil
 }
 
-func parseFlags(config *Config) {
-	flag.StringVar(&config.Server.Host, "host", "", "Server host")
-	flag.IntVar(&config.Server.Port, "port", 0, "Server port")
-	flag.BoolVar(&config.Debug, "debug", false, "Enable debug mode")
-	flag.Parse()
+// Load reads the configuration without validating it. Later sources override
+// earlier ones: defaults, the config file, the environment (including .env), flags.
+// Malformed values, such as a non-numeric port, are reported in a *ValidationError.
+func Load(args []string) (*Config, error) {
+	config, problems, err := load(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := problems.err(); err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
-func loadEnvironmentVariables(config *Config) {
-	// Server configuration
-	if config.Server.Host == "" {
-		config.Server.Host = getEnvOrDefault("SERVER_HOST", "0.0.0.0")
+// load reads the configuration, collecting malformed values in the returned ValidationError
+func load(args []string) (*Config, *ValidationError, error) {
+	// Parse command line flags
+	flags, err := parseFlags(args)
+	if err != nil {
+		return nil, nil, err
 	}
-	if config.Server.Port == 0 {
-		config.Server.Port = getEnvAsIntOrDefault("SERVER_PORT", 8080)
+
+	// Load .env file if it exists
+	if err := loadDotEnv(); err != nil {
+		log.Printf("Warning: .env file not found or couldn't be loaded: %v", err)
 	}
 
-	// Database configuration
-	config.Database.Type = DatabaseType(getEnvOrDefault("DB_TYPE", "sqlite"))
-	config.Database.Host = getEnvOrDefault("DB_HOST", "localhost")
-	config.Database.Port = getEnvAsIntOrDefault("DB_PORT", 5432)
-	config.Database.Username = getEnvOrDefault("DB_USERNAME", "")
-	config.Database.Password = getEnvOrDefault("DB_PASSWORD", "")
-	config.Database.Database = getEnvOrDefault("DB_DATABASE", "app.db")
-	config.Database.SSLMode = getEnvOrDefault("DB_SSL_MODE", "disable")
+	config := defaultConfig()
+	problems := &ValidationError{}
 
-	// Database connection pool
-	config.Database.MaxOpenConns = getEnvAsIntOrDefault("DB_MAX_OPEN_CONNS", 25)
-	config.Database.MaxIdleConns = getEnvAsIntOrDefault("DB_MAX_IDLE_CONNS", 5)
-	config.Database.ConnMaxLifetime = getEnvAsDurationOrDefault("DB_CONN_MAX_LIFETIME", 5*time.Minute)
+	// Load the config file, if any
+	configFile := flags.configFile
+	if configFile == "" {
+		configFile = os.Getenv("CONFIG_FILE")
+	}
+	if configFile != "" {
+		if err := loadConfigFile(config, configFile, problems); err != nil {
+			return nil, nil, err
+		}
+	}
 
-	// Authentication configuration
-	config.Auth.JWTSecret = getEnvOrDefault("JWT_SECRET", "your-secret-key")
-	config.Auth.JWTExpiresIn = getEnvAsDurationOrDefault("JWT_EXPIRES_IN", 24*time.Hour)
-	config.Auth.JWTIssuer = getEnvOrDefault("JWT_ISSUER", "webui-skeleton")
-	config.Auth.GoogleClientID = getEnvOrDefault("GOOGLE_CLIENT_ID", "")
-	config.Auth.GoogleClientSecret = getEnvOrDefault("GOOGLE_CLIENT_SECRET", "")
-	config.Auth.GoogleRedirectURL = getEnvOrDefault("GOOGLE_REDIRECT_URL", "")
-	config.Auth.SessionSecret = getEnvOrDefault("SESSION_SECRET", "your-session-secret")
-	config.Auth.RequireAuth = getEnvAsBoolOrDefault("REQUIRE_AUTH", false)
+	// Load environment variables
+	loadEnvironmentVariables(config, envReader{problems: problems})
 
-	// Logging configuration
-	if !config.Debug {
-		config.Debug = getEnvAsBoolOrDefault("DEBUG", false)
+	// Load secrets from their files, the environment and the encrypted secrets file
+	config.secretSources = secretSources(config)
+	loadSecrets(config, problems)
+
+	// Apply the flags given on the command line
+	for _, override := range flags.overrides {
+		override(config)
 	}
-	config.LogLevel = getEnvOrDefault("LOG_LEVEL", "info")
+
+	return config, problems, nil
 }
 
-func validateConfig(config *Config) error {
-	if config.Server.Port <= 0 || config.Server.Port > 65535 {
-		return fmt.Errorf("invalid server port: %d", config.Server.Port)
+// dotenvKeys are the variables set from .env. A reload updates them, while
+// variables from the real environment keep taking precedence over .env.
+var (
+	dotenvMu   sync.Mutex
+	dotenvKeys = map[string]bool{}
+)
+
+// loadDotEnv sets the variables in .env that the environment doesn't set
+func loadDotEnv() error {
+	values, err := godotenv.Read()
+	if err != nil {
+		return err
 	}
 
-	if config.Auth.RequireAuth {
-		if config.Auth.JWTSecret == "" || config.Auth.JWTSecret == "your-secret-key" {
-			return fmt.Errorf("JWT secret must be set when authentication is required")
-		}
-		if config.Auth.GoogleClientID == "" || config.Auth.GoogleClientSecret == "" {
-			return fmt.Errorf("Google OAuth credentials must be set when authentication is required")
+	dotenvMu.Lock()
+	defer dotenvMu.Unlock()
+
+	// Variables removed from .env since the last load are unset again
+	for key := range dotenvKeys {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
+			delete(dotenvKeys, key)
 		}
 	}
 
+	for key, value := range values {
+		if _, set := os.LookupEnv(key); set && !dotenvKeys[key] {
+			continue
+		}
+		os.Setenv(key, value)
+		dotenvKeys[key] = true
+	}
 	return nil
 }
 
-// Helper functions
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
+// Validate checks the configuration for invalid or inconsistent settings,
+// returning a *ValidationError that lists all of them
+func (c *Config) Validate() error {
+	problems := &ValidationError{}
+	validateConfig(c, problems)
+	return problems.err()
 }
 
-func getEnvAsIntOrDefault(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+// Redacted returns a copy of the configuration with secrets masked, safe to print or log
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	for _, setting := range secretSettings {
+		if secret := setting.field(&redacted); *secret != "" {
+			*secret = redactedValue
 		}
 	}
-	return defaultValue
+	return &redacted
 }
 
-func getEnvAsBoolOrDefault(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		if boolValue, err := strconv.ParseBool(value); err == nil {
-			return boolValue
+// redactedValue replaces secrets in Redacted
+const redactedValue = "[REDACTED]"
+
+// commandLine holds the configuration flags given on the command line
+type commandLine struct {
+	configFile string
+	overrides  []func(*Config)
+}
+
+func parseFlags(args []string) (*commandLine, error) {
+	var (
+		cl    commandLine
+		host  string
+		port  int
+		debug bool
+	)
+
+	flags := flag.NewFlagSet("webui-be", flag.ContinueOnError)
+	flags.StringVar(&cl.configFile, "config", "", "Configuration file (.yaml, .yml, .toml or .json)")
+	flags.StringVar(&host, "host", "", "Server host")
+	flags.IntVar(&port, "port", 0, "Server port")
+	flags.BoolVar(&debug, "debug", false, "Enable debug mode")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	// Only flags that were given override the other sources
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "host":
+			cl.overrides = append(cl.overrides, func(c *Config) { c.Server.Host = host })
+		case "port":
+			cl.overrides = append(cl.overrides, func(c *Config) { c.Server.Port = port })
+		case "debug":
+			cl.overrides = append(cl.overrides, func(c *Config) { c.Debug = debug })
 		}
+	})
+
+	return &cl, nil
+}
+
+// defaultConfig returns the configuration used when no other source sets a value
+func defaultConfig() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Host: "0.0.0.0",
+			Port: 8080,
+			TLS: TLSConfig{
+				MinVersion:     "1.2",
+				CipherPolicy:   "default",
+				ReloadInterval: time.Minute,
+				HSTSMaxAge:     365 * 24 * time.Hour,
+			},
+		},
+		Database: DatabaseConfig{
+			Type:            SQLite,
+			Host:            "localhost",
+			Port:            5432,
+			Database:        "app.db",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		Auth: AuthConfig{
+			JWTSecret:              defaultJWTSecret,
+			JWTExpiresIn:           15 * time.Minute,
+			JWTIssuer:              "webui-skeleton",
+			JWTAlgorithm:           "HS256",
+			JWTKeyRotationInterval: 24 * time.Hour,
+			JWTKeyOverlap:          time.Hour,
+			RefreshTokenExpiresIn:  30 * 24 * time.Hour,
+			GitLabBaseURL:          "https://gitlab.com",
+			OIDCName:               "oidc",
+			OIDCDisplayName:        "Single Sign-On",
+			OIDCScopes:             []string{"openid", "email", "profile"},
+			SessionSecret:          defaultSessionSecret,
+			AccountLinking:         AccountLinkingVerifiedEmail,
+		},
+		Secrets: SecretsConfig{
+			RefreshInterval: time.Minute,
+		},
+		Metrics: MetricsConfig{
+			Enabled: true,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+			ServiceName: "webui-skeleton",
+		},
+		LogLevel: "info",
 	}
-	return defaultValue
 }
 
-func getEnvAsDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+// loadEnvironmentVariables overrides the configuration with the variables that are set.
+// Secrets are not read here but by loadSecrets, which also supports *_FILE variables.
+func loadEnvironmentVariables(config *Config, env envReader) {
+	// Server configuration
+	config.Server.Host = env.get("SERVER_HOST", config.Server.Host)
+	config.Server.Port = env.getInt("SERVER_PORT", config.Server.Port)
+	config.Server.ShutdownDelay = env.getDuration("SERVER_SHUTDOWN_DELAY", config.Server.ShutdownDelay)
+	config.Server.TrustedProxies = env.getSlice("TRUSTED_PROXIES", config.Server.TrustedProxies)
+	config.Server.TLS.CertFile = env.get("TLS_CERT_FILE", config.Server.TLS.CertFile)
+	config.Server.TLS.KeyFile = env.get("TLS_KEY_FILE", config.Server.TLS.KeyFile)
+	config.Server.TLS.MinVersion = env.get("TLS_MIN_VERSION", config.Server.TLS.MinVersion)
+	config.Server.TLS.CipherPolicy = env.get("TLS_CIPHER_POLICY", config.Server.TLS.CipherPolicy)
+	config.Server.TLS.ReloadInterval = env.getDuration("TLS_RELOAD_INTERVAL", config.Server.TLS.ReloadInterval)
+	config.Server.TLS.ClientCAFile = env.get("TLS_CLIENT_CA_FILE", config.Server.TLS.ClientCAFile)
+	config.Server.TLS.RedirectListen = env.get("TLS_REDIRECT_LISTEN", config.Server.TLS.RedirectListen)
+	config.Server.TLS.HSTSMaxAge = env.getDuration("TLS_HSTS_MAX_AGE", config.Server.TLS.HSTSMaxAge)
+	config.Server.TLS.HSTSIncludeSubdomains = env.getBool("TLS_HSTS_INCLUDE_SUBDOMAINS", config.Server.TLS.HSTSIncludeSubdomains)
+
+	// Database configuration
+	config.Database.Type = DatabaseType(env.get("DB_TYPE", string(config.Database.Type)))
+	config.Database.Host = env.get("DB_HOST", config.Database.Host)
+	config.Database.Port = env.getInt("DB_PORT", config.Database.Port)
+	config.Database.Username = env.get("DB_USERNAME", config.Database.Username)
+	config.Database.Database = env.get("DB_DATABASE", config.Database.Database)
+	config.Database.SSLMode = env.get("DB_SSL_MODE", config.Database.SSLMode)
+
+	// Database connection pool
+	config.Database.MaxOpenConns = env.getInt("DB_MAX_OPEN_CONNS", config.Database.MaxOpenConns)
+	config.Database.MaxIdleConns = env.getInt("DB_MAX_IDLE_CONNS", config.Database.MaxIdleConns)
+	config.Database.ConnMaxLifetime = env.getDuration("DB_CONN_MAX_LIFETIME", config.Database.ConnMaxLifetime)
+
+	// Authentication configuration
+	config.Auth.JWTExpiresIn = env.getDuration("JWT_EXPIRES_IN", config.Auth.JWTExpiresIn)
+	config.Auth.JWTIssuer = env.get("JWT_ISSUER", config.Auth.JWTIssuer)
+	config.Auth.RefreshTokenExpiresIn = env.getDuration("REFRESH_TOKEN_EXPIRES_IN", config.Auth.RefreshTokenExpiresIn)
+	config.Auth.JWTAlgorithm = env.get("JWT_ALGORITHM", config.Auth.JWTAlgorithm)
+	config.Auth.JWTPrivateKeyFiles = env.getSlice("JWT_PRIVATE_KEY_FILES", config.Auth.JWTPrivateKeyFiles)
+	config.Auth.JWTKeyRotationInterval = env.getDuration("JWT_KEY_ROTATION_INTERVAL", config.Auth.JWTKeyRotationInterval)
+	config.Auth.JWTKeyOverlap = env.getDuration("JWT_KEY_OVERLAP", config.Auth.JWTKeyOverlap)
+	config.Auth.GoogleClientID = env.get("GOOGLE_CLIENT_ID", config.Auth.GoogleClientID)
+	config.Auth.GoogleRedirectURL = env.get("GOOGLE_REDIRECT_URL", config.Auth.GoogleRedirectURL)
+	config.Auth.GitHubClientID = env.get("GITHUB_CLIENT_ID", config.Auth.GitHubClientID)
+	config.Auth.GitHubRedirectURL = env.get("GITHUB_REDIRECT_URL", config.Auth.GitHubRedirectURL)
+	config.Auth.GitLabBaseURL = env.get("GITLAB_BASE_URL", config.Auth.GitLabBaseURL)
+	config.Auth.GitLabClientID = env.get("GITLAB_CLIENT_ID", config.Auth.GitLabClientID)
+	config.Auth.GitLabRedirectURL = env.get("GITLAB_REDIRECT_URL", config.Auth.GitLabRedirectURL)
+	config.Auth.OIDCName = env.get("OIDC_NAME", config.Auth.OIDCName)
+	config.Auth.OIDCDisplayName = env.get("OIDC_DISPLAY_NAME", config.Auth.OIDCDisplayName)
+	config.Auth.OIDCIssuerURL = env.get("OIDC_ISSUER_URL", config.Auth.OIDCIssuerURL)
+	config.Auth.OIDCClientID = env.get("OIDC_CLIENT_ID", config.Auth.OIDCClientID)
+	config.Auth.OIDCRedirectURL = env.get("OIDC_REDIRECT_URL", config.Auth.OIDCRedirectURL)
+	config.Auth.OIDCScopes = env.getSlice("OIDC_SCOPES", config.Auth.OIDCScopes)
+	config.Auth.RequireAuth = env.getBool("REQUIRE_AUTH", config.Auth.RequireAuth)
+	config.Auth.AccountLinking = AccountLinkingPolicy(env.get("ACCOUNT_LINKING", string(config.Auth.AccountLinking)))
+	config.Auth.AdminEmails = env.getSlice("ADMIN_EMAILS", config.Auth.AdminEmails)
+	config.Auth.ClientCertPrincipals = env.getSlice("CLIENT_CERT_PRINCIPALS", config.Auth.ClientCertPrincipals)
+
+	// Secret sources; the secrets themselves are read by loadSecrets
+	config.Secrets.File = env.get("SECRETS_FILE", config.Secrets.File)
+	config.Secrets.KeyFile = env.get("SECRETS_KEY_FILE", config.Secrets.KeyFile)
+	config.Secrets.RefreshInterval = env.getDuration("SECRETS_REFRESH_INTERVAL", config.Secrets.RefreshInterval)
+
+	// Metrics configuration
+	config.Metrics.Enabled = env.getBool("METRICS_ENABLED", config.Metrics.Enabled)
+	config.Metrics.Listen = env.get("METRICS_LISTEN", config.Metrics.Listen)
+
+	// Tracing configuration
+	config.Tracing.Exporter = env.get("TRACING_EXPORTER", config.Tracing.Exporter)
+	config.Tracing.Endpoint = env.get("TRACING_ENDPOINT", config.Tracing.Endpoint)
+	config.Tracing.SampleRatio = env.getFloat("TRACING_SAMPLE_RATIO", config.Tracing.SampleRatio)
+	config.Tracing.ServiceName = env.get("TRACING_SERVICE_NAME", config.Tracing.ServiceName)
+
+	// Logging configuration
+	config.Debug = env.getBool("DEBUG", config.Debug)
+	config.LogLevel = env.get("LOG_LEVEL", config.LogLevel)
+}
+
+// envReader reads typed environment variables. Malformed values are recorded
+// as problems instead of silently falling back to the current value.
+type envReader struct {
+	problems *ValidationError
+}
+
+// get returns the variable, or current if it is unset
+func (env envReader) get(key, current string) string {
 	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
-		}
+		return value
 	}
-	return defaultValue
+	return current
 }
 
-func getEnvAsSliceOrDefault(key string, defaultValue []string, separator string) []string {
-	if value := os.Getenv(key); value != "" {
-		return strings.Split(value, separator)
+func (env envReader) getInt(key string, current int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return current
+	}
+	intValue, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		env.problems.add(key, "invalid integer %q", value)
+		return current
+	}
+	return intValue
+}
+
+func (env envReader) getBool(key string, current bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return current
+	}
+	boolValue, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		env.problems.add(key, "invalid boolean %q, use true or false", value)
+		return current
+	}
+	return boolValue
+}
+
+func (env envReader) getFloat(key string, current float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return current
+	}
+	floatValue, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		env.problems.add(key, "invalid number %q", value)
+		return current
+	}
+	return floatValue
+}
+
+func (env envReader) getDuration(key string, current time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return current
+	}
+	duration, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		env.problems.add(key, "invalid duration %q, use a value such as 15m or 24h", value)
+		return current
+	}
+	return duration
+}
+
+// getSlice splits a comma-separated variable, dropping blank items
+func (env envReader) getSlice(key string, current []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return current
+	}
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
-	return defaultValue
+	return items
 }
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// isolateEnv clears the variables the tests set, so the outer environment can't leak in
+func isolateEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{"CONFIG_FILE", "SERVER_HOST", "SERVER_PORT", "DEBUG", "JWT_SECRET", "JWT_SECRET_FILE", "SESSION_SECRET", "SESSION_SECRET_FILE", "SECRETS_FILE", "SECRETS_KEY_FILE"} {
+		t.Setenv(key, "")
+	}
+}
+
+// writeFile writes content to name in a temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	const configFile = `
+server:
+  host: file.example.com
+  port: 9000
+auth:
+  jwt_secret: file-secret
+debug: false
+`
+
+	tests := []struct {
+		name       string
+		file       bool
+		env        map[string]string
+		secretFile string
+		args       []string
+		wantHost   string
+		wantPort   int
+		wantSecret string
+		wantDebug  bool
+	}{
+		{
+			name:       "defaults",
+			wantHost:   "0.0.0.0",
+			wantPort:   8080,
+			wantSecret: "your-secret-key",
+		},
+		{
+			name:       "file over defaults",
+			file:       true,
+			wantHost:   "file.example.com",
+			wantPort:   9000,
+			wantSecret: "file-secret",
+		},
+		{
+			name:       "environment over file",
+			file:       true,
+			env:        map[string]string{"SERVER_PORT": "9100", "JWT_SECRET": "env-secret", "DEBUG": "true"},
+			wantHost:   "file.example.com",
+			wantPort:   9100,
+			wantSecret: "env-secret",
+			wantDebug:  true,
+		},
+		{
+			name:       "secret file over environment",
+			file:       true,
+			env:        map[string]string{"JWT_SECRET": "env-secret"},
+			secretFile: "file-mounted-secret\n",
+			wantHost:   "file.example.com",
+			wantPort:   9000,
+			wantSecret: "file-mounted-secret",
+		},
+		{
+			name:       "flags over everything",
+			file:       true,
+			env:        map[string]string{"SERVER_HOST": "env.example.com", "SERVER_PORT": "9100", "DEBUG": "true"},
+			args:       []string{"-host", "flag.example.com", "-port", "9200", "-debug=false"},
+			wantHost:   "flag.example.com",
+			wantPort:   9200,
+			wantSecret: "file-secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if tt.file {
+				t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", configFile))
+			}
+			if tt.secretFile != "" {
+				t.Setenv("JWT_SECRET_FILE", writeFile(t, "jwt_secret", tt.secretFile))
+			}
+
+			config, err := Load(tt.args)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if config.Server.Host != tt.wantHost {
+				t.Errorf("host = %q, want %q", config.Server.Host, tt.wantHost)
+			}
+			if config.Server.Port != tt.wantPort {
+				t.Errorf("port = %d, want %d", config.Server.Port, tt.wantPort)
+			}
+			if config.Auth.JWTSecret != tt.wantSecret {
+				t.Errorf("jwt secret = %q, want %q", config.Auth.JWTSecret, tt.wantSecret)
+			}
+			if config.Debug != tt.wantDebug {
+				t.Errorf("debug = %t, want %t", config.Debug, tt.wantDebug)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileFormats(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": "server:\n  port: 9000\n  shutdown_delay: 5s\n",
+		"config.toml": "[server]\nport = 9000\nshutdown_delay = \"5s\"\n",
+		"config.json": `{"server": {"port": 9000, "shutdown_delay": "5s"}}`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			isolateEnv(t)
+			config, err := Load([]string{"-config", writeFile(t, name, content)})
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if config.Server.Port != 9000 || config.Server.ShutdownDelay.String() != "5s" {
+				t.Errorf("server = port %d, shutdown delay %v, want 9000 and 5s", config.Server.Port, config.Server.ShutdownDelay)
+			}
+		})
+	}
+}
+
+func TestLoadReportsProblems(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "jwt_secret")
+
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		want []FieldError
+	}{
+		{
+			name: "unknown keys",
+			file: "bogus: 1\nserver:\n  prot: 9000\n  tls:\n    cert: x\n",
+			want: []FieldError{
+				{Field: "bogus", Message: "unknown setting"},
+				{Field: "server.prot", Message: "unknown setting"},
+				{Field: "server.tls.cert", Message: "unknown setting"},
+			},
+		},
+		{
+			name: "mistyped values",
+			file: "server:\n  port: eighty\n  shutdown_delay: 10\n  trusted_proxies: 10.0.0.1\n  tls: true\ndebug: yes please\n",
+			want: []FieldError{
+				{Field: "debug", Message: "expected true or false"},
+				{Field: "server.port", Message: "expected an integer"},
+				{Field: "server.shutdown_delay", Message: `expected a duration string such as "15m"`},
+				{Field: "server.tls", Message: "expected a table of settings"},
+				{Field: "server.trusted_proxies", Message: "expected a list of strings"},
+			},
+		},
+		{
+			name: "malformed environment variables",
+			env:  map[string]string{"SERVER_PORT": "eighty", "DEBUG": "maybe"},
+			want: []FieldError{
+				{Field: "SERVER_PORT", Message: `invalid integer "eighty"`},
+				{Field: "DEBUG", Message: `invalid boolean "maybe", use true or false`},
+			},
+		},
+		{
+			name: "unreadable secret file",
+			env:  map[string]string{"JWT_SECRET_FILE": missing},
+			want: []FieldError{
+				{Field: "secret file", Message: "failed to read JWT_SECRET_FILE: open " + missing + ": no such file or directory"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			var args []string
+			if tt.file != "" {
+				args = []string{"-config", writeFile(t, "config.yaml", tt.file)}
+			}
+
+			_, err := Load(args)
+			problems, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("Load() error = %v, want a *ValidationError", err)
+			}
+			if !reflect.DeepEqual(problems.Errors, tt.want) {
+				t.Errorf("Load() problems = %+v, want %+v", problems.Errors, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseClientCertPrincipal(t *testing.T) {
+	tests := []struct {
+		name         string
+		entry        string
+		wantIdentity string
+		wantEmail    string
+		wantErr      bool
+	}{
+		{name: "uri", entry: "uri:spiffe://example.org/billing=billing@example.com", wantIdentity: "uri:spiffe://example.org/billing", wantEmail: "billing@example.com"},
+		{name: "uri with a query", entry: "uri:https://example.org/svc?env=prod=billing@example.com", wantIdentity: "uri:https://example.org/svc?env=prod", wantEmail: "billing@example.com"},
+		{name: "dns lowercased", entry: "DNS:Reports.Example.org=reports@example.com", wantIdentity: "dns:reports.example.org", wantEmail: "reports@example.com"},
+		{name: "email", entry: "email:ops-bot@example.org=ops@example.com", wantIdentity: "email:ops-bot@example.org", wantEmail: "ops@example.com"},
+		{name: "cn keeps case, spaces trimmed", entry: " cn:Legacy Batch = batch@example.com ", wantIdentity: "cn:Legacy Batch", wantEmail: "batch@example.com"},
+		{name: "no separator", entry: "cn:billing", wantErr: true},
+		{name: "no kind", entry: "billing=billing@example.com", wantErr: true},
+		{name: "unknown kind", entry: "ip:10.0.0.1=billing@example.com", wantErr: true},
+		{name: "empty identity", entry: "cn:=billing@example.com", wantErr: true},
+		{name: "empty entry", entry: "=billing@example.com", wantErr: true},
+		{name: "not an email", entry: "cn:billing=billing", wantErr: true},
+		{name: "empty email", entry: "cn:billing=", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, email, err := ParseClientCertPrincipal(tt.entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseClientCertPrincipal(%q) error = %v, wantErr %v", tt.entry, err, tt.wantErr)
+			}
+			if identity != tt.wantIdentity || email != tt.wantEmail {
+				t.Errorf("ParseClientCertPrincipal(%q) = %q, %q, want %q, %q", tt.entry, identity, email, tt.wantIdentity, tt.wantEmail)
+			}
+		})
+	}
+}
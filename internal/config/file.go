@@ -0,0 +1,231 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// loadConfigFile reads a YAML, TOML or JSON file over config. Keys are the json
+// tags of the Config fields, nested like the structs; durations are strings such
+// as "15m". Unknown keys are reported as problems so that typos don't go unnoticed;
+// the returned error is for files that can't be read or parsed.
+func loadConfigFile(config *Config, path string, problems *ValidationError) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values := map[string]interface{}{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("unsupported config file format %q: use .yaml, .yml, .toml or .json", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	decodeValues(reflect.ValueOf(config).Elem(), values, "", problems)
+	return nil
+}
+
+// decodeValues sets the fields of struct v from values keyed by json tag,
+// recording a problem for every unknown key and mistyped value
+func decodeValues(v reflect.Value, values map[string]interface{}, prefix string, problems *ValidationError) {
+	fields := fieldsByTag(v)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field, ok := fields[key]
+		if !ok {
+			problems.add(prefix+key, "unknown setting")
+			continue
+		}
+		decodeValue(field, values[key], prefix+key, problems)
+	}
+}
+
+// decodeValue converts a parsed file value to the type of field and sets it. Null values are ignored.
+func decodeValue(field reflect.Value, raw interface{}, path string, problems *ValidationError) {
+	if raw == nil {
+		return
+	}
+
+	if field.Type() == durationType {
+		s, ok := raw.(string)
+		if !ok {
+			problems.add(path, "expected a duration string such as \"15m\"")
+			return
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			problems.add(path, "invalid duration %q", s)
+			return
+		}
+		field.SetInt(int64(d))
+		return
+	}
+
+	switch field.Kind() {
+	case reflect.Struct:
+		values, ok := raw.(map[string]interface{})
+		if !ok {
+			problems.add(path, "expected a table of settings")
+			return
+		}
+		decodeValues(field, values, path+".", problems)
+	case reflect.String:
+		s, ok := raw.(string)
+		if !ok {
+			problems.add(path, "expected a string")
+			return
+		}
+		field.SetString(s)
+	case reflect.Int:
+		n, ok := toInt(raw)
+		if !ok {
+			problems.add(path, "expected an integer")
+			return
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, ok := toFloat(raw)
+		if !ok {
+			problems.add(path, "expected a number")
+			return
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, ok := raw.(bool)
+		if !ok {
+			problems.add(path, "expected true or false")
+			return
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		items, ok := raw.([]interface{})
+		if !ok {
+			problems.add(path, "expected a list of strings")
+			return
+		}
+		list := make([]string, len(items))
+		for i, item := range items {
+			if list[i], ok = item.(string); !ok {
+				problems.add(path, "expected a list of strings")
+				return
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		problems.add(path, "unsupported setting type %s", field.Type())
+	}
+}
+
+// toInt accepts the integer representations of the YAML, TOML and JSON parsers
+func toInt(raw interface{}) (int64, bool) {
+	switch n := raw.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint64:
+		return int64(n), n <= math.MaxInt64
+	case float64:
+		return int64(n), n == math.Trunc(n)
+	}
+	return 0, false
+}
+
+// toFloat accepts the number representations of the YAML, TOML and JSON parsers
+func toFloat(raw interface{}) (float64, bool) {
+	if f, ok := raw.(float64); ok {
+		return f, true
+	}
+	n, ok := toInt(raw)
+	return float64(n), ok
+}
+
+// fieldsByTag maps the json tag names of a struct's fields to the fields
+func fieldsByTag(v reflect.Value) map[string]reflect.Value {
+	fields := map[string]reflect.Value{}
+	for i := 0; i < v.NumField(); i++ {
+		name := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = v.Field(i)
+		}
+	}
+	return fields
+}
+
+// Encode writes the configuration as a config file in format (yaml, toml or json)
+// that loads back to the same configuration
+func (c *Config) Encode(w io.Writer, format string) error {
+	values := c.Settings()
+
+	switch format {
+	case "yaml", "yml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		return encoder.Encode(values)
+	case "toml":
+		return toml.NewEncoder(w).Encode(values)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(values)
+	default:
+		return fmt.Errorf("unsupported config format %q: use yaml, toml or json", format)
+	}
+}
+
+// Settings returns the configuration as config file values, by json tag name
+func (c *Config) Settings() map[string]interface{} {
+	return encodeValues(reflect.ValueOf(c).Elem())
+}
+
+// encodeValues is the inverse of decodeValues
+func encodeValues(v reflect.Value) map[string]interface{} {
+	values := map[string]interface{}{}
+	for name, field := range fieldsByTag(v) {
+		switch {
+		case field.Type() == durationType:
+			values[name] = time.Duration(field.Int()).String()
+		case field.Kind() == reflect.Struct:
+			values[name] = encodeValues(field)
+		case field.Kind() == reflect.Slice:
+			list := []string{}
+			for i := 0; i < field.Len(); i++ {
+				list = append(list, field.Index(i).String())
+			}
+			values[name] = list
+		case field.Kind() == reflect.String:
+			values[name] = field.String()
+		default:
+			values[name] = field.Interface()
+		}
+	}
+	return values
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// liveSettings are applied while the server runs, along with the secrets;
+// changes to any other setting are reported as needing a restart. A new live
+// setting also needs a subscriber that applies it, see Server.applyConfig.
+var liveSettings = map[string]bool{
+	"log_level":                   true,
+	"auth.require_auth":           true,
+	"auth.account_linking":        true,
+	"auth.admin_emails":           true,
+	"auth.client_cert_principals": true,
+	"auth.google_client_id":       true,
+	"auth.google_redirect_url":    true,
+	"auth.github_client_id":       true,
+	"auth.github_redirect_url":    true,
+	"auth.gitlab_base_url":        true,
+	"auth.gitlab_client_id":       true,
+	"auth.gitlab_redirect_url":    true,
+	"auth.oidc_name":              true,
+	"auth.oidc_display_name":      true,
+	"auth.oidc_issuer_url":        true,
+	"auth.oidc_client_id":         true,
+	"auth.oidc_redirect_url":      true,
+	"auth.oidc_scopes":            true,
+}
+
+// Change is a setting that differs between two configurations. Secret values are redacted.
+type Change struct {
+	Setting string `json:"setting"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+
+	// Live changes are applied without a restart
+	Live bool `json:"live"`
+}
+
+// Diff lists the settings that differ between old and new, by config file key
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	diffValues(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), "", &changes)
+	return changes
+}
+
+// diffValues compares the fields of two structs of the same type
+func diffValues(old, new reflect.Value, prefix string, changes *[]Change) {
+	oldFields, newFields := fieldsByTag(old), fieldsByTag(new)
+
+	names := make([]string, 0, len(oldFields))
+	for name := range oldFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := prefix + name
+		oldField, newField := oldFields[name], newFields[name]
+		if oldField.Kind() == reflect.Struct {
+			diffValues(oldField, newField, path+".", changes)
+			continue
+		}
+
+		oldValue, newValue := formatSetting(oldField), formatSetting(newField)
+		if oldValue == newValue {
+			continue
+		}
+		if isSecret(path) {
+			oldValue, newValue = redact(oldValue), redact(newValue)
+		}
+		*changes = append(*changes, Change{
+			Setting: path,
+			Old:     oldValue,
+			New:     newValue,
+			Live:    liveSettings[path] || isSecret(path),
+		})
+	}
+}
+
+// formatSetting renders a setting the way it is written in environment variables
+func formatSetting(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = v.Index(i).String()
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// isSecret reports whether the setting at path is a secret
+func isSecret(path string) bool {
+	for _, setting := range secretSettings {
+		if setting.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// copySetting sets the setting at path in dst to its value in src
+func copySetting(dst, src *Config, path string) {
+	dstValue, srcValue := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for _, name := range strings.Split(path, ".") {
+		dstValue, srcValue = fieldsByTag(dstValue)[name], fieldsByTag(srcValue)[name]
+	}
+	dstValue.Set(srcValue)
+}
+
+// Subscriber is notified with the updated configuration and the live changes applied to it
+type Subscriber func(cfg *Config, changes []Change)
+
+// Manager holds the running configuration and reloads it. Reloads apply the live
+// settings and notify the subscribers; other changes wait for a restart.
+type Manager struct {
+	args []string
+
+	// reloadMu serializes reloads and notifications; mu guards current
+	reloadMu    sync.Mutex
+	mu          sync.RWMutex
+	current     *Config
+	subscribers []Subscriber
+}
+
+// NewManager creates a manager for the configuration loaded from args
+func NewManager(cfg *Config, args []string) *Manager {
+	return &Manager{args: args, current: cfg}
+}
+
+// Current returns the running configuration. It must not be modified.
+func (m *Manager) Current() *Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.current
+}
+
+// Subscribe registers fn to be called after every reload that applied live changes
+func (m *Manager) Subscribe(fn Subscriber) {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+	m.subscribers = append(m.subscribers, fn)
+}
+
+// Reload loads the configuration again from the config file, .env, the environment
+// and the secret sources. An invalid configuration is rejected as a whole.
+// It returns every change, live or not.
+func (m *Manager) Reload() ([]Change, error) {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	next, err := LoadConfiguration(m.args)
+	if err != nil {
+		return nil, err
+	}
+	return m.apply(next), nil
+}
+
+// ReloadSecrets reads the secrets again, so that rotated values apply without a restart
+func (m *Manager) ReloadSecrets() ([]Change, error) {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	next, err := m.Current().ReloadSecrets()
+	if err != nil {
+		return nil, err
+	}
+	return m.apply(next), nil
+}
+
+// apply makes the live changes from next current and notifies the subscribers.
+// The caller holds reloadMu.
+func (m *Manager) apply(next *Config) []Change {
+	current := m.Current()
+	changes := Diff(current, next)
+
+	updated := *current
+	var live []Change
+	for _, change := range changes {
+		if change.Live {
+			copySetting(&updated, next, change.Setting)
+			live = append(live, change)
+		}
+	}
+	if len(live) == 0 {
+		return changes
+	}
+
+	m.mu.Lock()
+	m.current = &updated
+	m.mu.Unlock()
+
+	for _, fn := range m.subscribers {
+		fn(&updated, live)
+	}
+	return changes
+}
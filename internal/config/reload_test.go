@@ -0,0 +1,162 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// reloadTestConfig is a valid config file; reloads rewrite it with changes
+const reloadTestConfig = `
+log_level: info
+server:
+  port: 8080
+auth:
+  jwt_secret: ` + testJWTSecret + `
+  session_secret: ` + testSessionSecret + `
+`
+
+// newTestManager loads the config file at path into a manager
+func newTestManager(t *testing.T, path string) *Manager {
+	t.Helper()
+	isolateEnv(t)
+	args := []string{"-config", path}
+	cfg, err := LoadConfiguration(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewManager(cfg, args)
+}
+
+func TestManagerReload(t *testing.T) {
+	path := writeFile(t, "config.yaml", reloadTestConfig)
+	manager := newTestManager(t, path)
+	var notified [][]Change
+	manager.Subscribe(func(cfg *Config, changes []Change) { notified = append(notified, changes) })
+
+	rewritten := `
+log_level: debug
+server:
+  port: 9090
+auth:
+  account_linking: never
+  jwt_secret: ` + testJWTSecret + `
+  session_secret: ` + rotatedJWTSecret + `
+`
+	if err := os.WriteFile(path, []byte(rewritten), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := manager.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	want := []Change{
+		{Setting: "auth.account_linking", Old: "verified_email", New: "never", Live: true},
+		{Setting: "auth.session_secret", Old: redactedValue, New: redactedValue, Live: true},
+		{Setting: "log_level", Old: "info", New: "debug", Live: true},
+		{Setting: "server.port", Old: "8080", New: "9090", Live: false},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Reload() changes = %+v, want %+v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+
+	// Live settings are applied; the port waits for a restart
+	current := manager.Current()
+	if current.LogLevel != "debug" || current.Auth.AccountLinking != AccountLinkingNever || current.Auth.SessionSecret != rotatedJWTSecret {
+		t.Errorf("current = log level %q, account linking %q, want the reloaded values", current.LogLevel, current.Auth.AccountLinking)
+	}
+	if current.Server.Port != 8080 {
+		t.Errorf("current port = %d, want 8080 until a restart", current.Server.Port)
+	}
+	if len(notified) != 1 || len(notified[0]) != 3 {
+		t.Fatalf("subscribers notified with %+v, want the 3 live changes once", notified)
+	}
+	for _, change := range notified[0] {
+		if !change.Live {
+			t.Errorf("subscribers notified of %s, which needs a restart", change.Setting)
+		}
+	}
+
+	// Reloading again reports the pending restart, but applies and notifies nothing
+	changes, err = manager.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Setting != "server.port" || changes[0].Live {
+		t.Errorf("second Reload() changes = %+v, want only server.port", changes)
+	}
+	if len(notified) != 1 {
+		t.Errorf("subscribers notified %d times, want once", len(notified))
+	}
+}
+
+func TestManagerReloadInvalid(t *testing.T) {
+	path := writeFile(t, "config.yaml", reloadTestConfig)
+	manager := newTestManager(t, path)
+	before := manager.Current()
+	notified := false
+	manager.Subscribe(func(cfg *Config, changes []Change) { notified = true })
+
+	// Each also changes log_level, which must not be applied either
+	invalid := map[string]string{
+		"invalid value": strings.Replace(reloadTestConfig, "auth:\n", "auth:\n  account_linking: always\n", 1),
+		"unknown key":   reloadTestConfig + "log_levle: trace\n",
+		"parse error":   reloadTestConfig + "tracing: [none\n",
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			content = strings.Replace(content, "log_level: info", "log_level: debug", 1)
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := manager.Reload()
+			if err == nil {
+				t.Fatal("Reload() succeeded, want an error")
+			}
+			var problems *ValidationError
+			if isProblem := errors.As(err, &problems); isProblem == (name == "parse error") {
+				t.Errorf("Reload() error = %v", err)
+			}
+			if manager.Current() != before {
+				t.Error("Reload() replaced the configuration")
+			}
+			if notified {
+				t.Error("Reload() notified the subscribers")
+			}
+		})
+	}
+}
+
+func TestDiffRedactsSecrets(t *testing.T) {
+	old := defaultConfig()
+	new := defaultConfig()
+	old.Database.Password = ""
+	new.Database.Password = "hunter2"
+	new.Auth.JWTSecret = "rotated"
+	new.Auth.GoogleClientSecret = "client-secret"
+
+	for _, change := range Diff(old, new) {
+		if change.Old == "hunter2" || change.New == "hunter2" || change.New == "rotated" || change.New == "client-secret" {
+			t.Errorf("Diff() change %+v exposes a secret", change)
+		}
+		if !change.Live {
+			t.Errorf("Diff() change %+v, want secrets to apply live", change)
+		}
+	}
+
+	changes := Diff(old, new)
+	if len(changes) != 3 {
+		t.Fatalf("Diff() = %+v, want 3 changes", changes)
+	}
+	// Setting or clearing a secret is still visible
+	if changes[0].Setting != "auth.google_client_secret" || changes[0].Old != "" || changes[0].New != redactedValue {
+		t.Errorf("Diff() change %+v, want a newly set secret shown as set", changes[0])
+	}
+}
@@ -0,0 +1,254 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// SecretSource provides secret settings by their environment variable name, e.g. JWT_SECRET
+type SecretSource interface {
+	// Name identifies the source in error messages
+	Name() string
+
+	// Lookup returns the value of a secret and whether the source has it
+	Lookup(key string) (string, bool, error)
+}
+
+// secretSettings are the settings read from secret sources, by environment variable name and config file key
+var secretSettings = []struct {
+	key   string
+	path  string
+	field func(*Config) *string
+}{
+	{"DB_PASSWORD", "database.password", func(c *Config) *string { return &c.Database.Password }},
+	{"JWT_SECRET", "auth.jwt_secret", func(c *Config) *string { return &c.Auth.JWTSecret }},
+	{"SESSION_SECRET", "auth.session_secret", func(c *Config) *string { return &c.Auth.SessionSecret }},
+	{"GOOGLE_CLIENT_SECRET", "auth.google_client_secret", func(c *Config) *string { return &c.Auth.GoogleClientSecret }},
+	{"GITHUB_CLIENT_SECRET", "auth.github_client_secret", func(c *Config) *string { return &c.Auth.GitHubClientSecret }},
+	{"GITLAB_CLIENT_SECRET", "auth.gitlab_client_secret", func(c *Config) *string { return &c.Auth.GitLabClientSecret }},
+	{"OIDC_CLIENT_SECRET", "auth.oidc_client_secret", func(c *Config) *string { return &c.Auth.OIDCClientSecret }},
+}
+
+// secretSources returns the sources secrets are read from, in order of precedence:
+// *_FILE variables, plain variables, then the encrypted secrets file if configured
+func secretSources(config *Config) []SecretSource {
+	sources := []SecretSource{FileSecretSource{}, EnvSecretSource{}}
+	if config.Secrets.File != "" {
+		sources = append(sources, NewEncryptedFileSecretSource(config.Secrets.File, config.Secrets.KeyFile))
+	}
+	return sources
+}
+
+// loadSecrets sets every secret setting that one of the config's sources provides.
+// A source that can't be read is reported once, not for every secret.
+func loadSecrets(config *Config, problems *ValidationError) {
+	reported := map[string]bool{}
+	for _, setting := range secretSettings {
+		for _, source := range config.secretSources {
+			value, ok, err := source.Lookup(setting.key)
+			if err != nil {
+				if !reported[err.Error()] {
+					reported[err.Error()] = true
+					problems.add(source.Name(), "%v", err)
+				}
+				break
+			}
+			if ok {
+				*setting.field(config) = value
+				break
+			}
+		}
+	}
+}
+
+// ReloadSecrets reads the secrets again from their sources and returns a copy of
+// the configuration with the new values. The copy is validated, so a rotated
+// secret that is too weak is refused.
+func (c *Config) ReloadSecrets() (*Config, error) {
+	updated := *c
+	problems := &ValidationError{}
+	loadSecrets(&updated, problems)
+	validateConfig(&updated, problems)
+	if err := problems.err(); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// EnvSecretSource reads secrets from environment variables
+type EnvSecretSource struct{}
+
+// Name implements SecretSource
+func (EnvSecretSource) Name() string { return "environment" }
+
+// Lookup implements SecretSource
+func (EnvSecretSource) Lookup(key string) (string, bool, error) {
+	value := os.Getenv(key)
+	return value, value != "", nil
+}
+
+// FileSecretSource reads secrets from the file named by the key's _FILE variable,
+// e.g. JWT_SECRET_FILE=/run/secrets/jwt_secret, as mounted by Docker and Kubernetes
+type FileSecretSource struct{}
+
+// Name implements SecretSource
+func (FileSecretSource) Name() string { return "secret file" }
+
+// Lookup implements SecretSource
+func (FileSecretSource) Lookup(key string) (string, bool, error) {
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return "", false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s_FILE: %w", key, err)
+	}
+
+	// Editors and echo add a trailing newline that isn't part of the secret
+	return strings.TrimRight(string(data), "\r\n"), true, nil
+}
+
+// EncryptedFileSecretSource reads secrets from a local file in .env format,
+// encrypted with NaCl secretbox. The file is decrypted again when it changes.
+type EncryptedFileSecretSource struct {
+	path    string
+	keyFile string
+
+	mu      sync.Mutex
+	modTime time.Time
+	values  map[string]string
+}
+
+// NewEncryptedFileSecretSource creates a source for the encrypted file at path,
+// decrypted with the hex-encoded key stored in keyFile
+func NewEncryptedFileSecretSource(path, keyFile string) *EncryptedFileSecretSource {
+	return &EncryptedFileSecretSource{path: path, keyFile: keyFile}
+}
+
+// Name implements SecretSource
+func (s *EncryptedFileSecretSource) Name() string { return "encrypted secrets file" }
+
+// Lookup implements SecretSource
+func (s *EncryptedFileSecretSource) Lookup(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s: %w", s.path, err)
+	}
+
+	if s.values == nil || !info.ModTime().Equal(s.modTime) {
+		values, err := s.decrypt()
+		if err != nil {
+			return "", false, err
+		}
+		s.values, s.modTime = values, info.ModTime()
+	}
+
+	value, ok := s.values[key]
+	return value, ok && value != "", nil
+}
+
+// decrypt reads and decrypts the secrets file
+func (s *EncryptedFileSecretSource) decrypt() (map[string]string, error) {
+	if s.keyFile == "" {
+		return nil, errors.New("SECRETS_KEY_FILE must be set to decrypt the secrets file")
+	}
+	key, err := os.ReadFile(s.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets key: %w", err)
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.path, err)
+	}
+
+	plaintext, err := DecryptSecrets(data, string(key))
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := godotenv.Unmarshal(string(plaintext))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted secrets: %w", err)
+	}
+	return values, nil
+}
+
+// secretsKeySize and secretsNonceSize are the NaCl secretbox key and nonce sizes
+const (
+	secretsKeySize   = 32
+	secretsNonceSize = 24
+)
+
+// GenerateSecretsKey returns a new random key for EncryptSecrets, hex-encoded
+func GenerateSecretsKey() (string, error) {
+	key := make([]byte, secretsKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// EncryptSecrets encrypts plaintext (secrets in .env format) with a hex-encoded key.
+// The result is the base64-encoded nonce followed by the sealed box.
+func EncryptSecrets(plaintext []byte, hexKey string) ([]byte, error) {
+	key, err := parseSecretsKey(hexKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [secretsNonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := secretbox.Seal(nonce[:], plaintext, &nonce, key)
+	return []byte(base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+// DecryptSecrets reverses EncryptSecrets
+func DecryptSecrets(data []byte, hexKey string) ([]byte, error) {
+	key, err := parseSecretsKey(hexKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(sealed) < secretsNonceSize {
+		return nil, errors.New("secrets file is not in the encrypted format")
+	}
+
+	var nonce [secretsNonceSize]byte
+	copy(nonce[:], sealed[:secretsNonceSize])
+	plaintext, ok := secretbox.Open(nil, sealed[secretsNonceSize:], &nonce, key)
+	if !ok {
+		return nil, errors.New("failed to decrypt secrets file: wrong key or corrupted file")
+	}
+	return plaintext, nil
+}
+
+// parseSecretsKey decodes a hex-encoded secretbox key
+func parseSecretsKey(hexKey string) (*[secretsKeySize]byte, error) {
+	raw, err := hex.DecodeString(strings.TrimSpace(hexKey))
+	if err != nil || len(raw) != secretsKeySize {
+		return nil, fmt.Errorf("secrets key must be %d hex-encoded bytes", secretsKeySize)
+	}
+
+	var key [secretsKeySize]byte
+	copy(key[:], raw)
+	return &key, nil
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const rotatedJWTSecret = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+// writeEncryptedSecrets encrypts content into a secrets file and returns its path and key file
+func writeEncryptedSecrets(t *testing.T, content string) (path, keyFile string) {
+	t.Helper()
+	key, err := GenerateSecretsKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := EncryptSecrets([]byte(content), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeFile(t, "secrets.enc", string(sealed)), writeFile(t, "secrets.key", key+"\n")
+}
+
+func TestLoadSecretsPrecedence(t *testing.T) {
+	encrypted, keyFile := writeEncryptedSecrets(t, "JWT_SECRET=from-encrypted-file\nSESSION_SECRET=session-from-encrypted-file\n")
+
+	tests := []struct {
+		name       string
+		env        map[string]string
+		secretFile string
+		want       string
+	}{
+		{name: "encrypted file", want: "from-encrypted-file"},
+		{name: "environment over encrypted file", env: map[string]string{"JWT_SECRET": "from-env"}, want: "from-env"},
+		{name: "secret file over environment", env: map[string]string{"JWT_SECRET": "from-env"}, secretFile: "from-secret-file\n", want: "from-secret-file"},
+		{name: "CRLF trimmed", secretFile: "from-secret-file\r\n", want: "from-secret-file"},
+		{name: "inner whitespace kept", secretFile: "  two words \n", want: "  two words "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateEnv(t)
+			t.Setenv("SECRETS_FILE", encrypted)
+			t.Setenv("SECRETS_KEY_FILE", keyFile)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if tt.secretFile != "" {
+				t.Setenv("JWT_SECRET_FILE", writeFile(t, "jwt_secret", tt.secretFile))
+			}
+
+			config, err := Load(nil)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if config.Auth.JWTSecret != tt.want {
+				t.Errorf("jwt secret = %q, want %q", config.Auth.JWTSecret, tt.want)
+			}
+			// Secrets the other sources don't set still come from the encrypted file
+			if config.Auth.SessionSecret != "session-from-encrypted-file" {
+				t.Errorf("session secret = %q, want it from the encrypted file", config.Auth.SessionSecret)
+			}
+		})
+	}
+}
+
+func TestEncryptSecrets(t *testing.T) {
+	key, err := GenerateSecretsKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := GenerateSecretsKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("JWT_SECRET=abc\nDB_PASSWORD=def\n")
+
+	sealed, err := EncryptSecrets(plaintext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("JWT_SECRET")) {
+		t.Fatal("EncryptSecrets() output contains the plaintext")
+	}
+	if again, _ := EncryptSecrets(plaintext, key); bytes.Equal(again, sealed) {
+		t.Error("EncryptSecrets() reused a nonce")
+	}
+
+	decrypted, err := DecryptSecrets(sealed, key)
+	if err != nil {
+		t.Fatalf("DecryptSecrets() error = %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("DecryptSecrets() = %q, want %q", decrypted, plaintext)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sealed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)-1] ^= 1
+	tampered := []byte(base64.StdEncoding.EncodeToString(raw))
+
+	rejected := []struct {
+		name string
+		data []byte
+		key  string
+	}{
+		{"wrong key", sealed, otherKey},
+		{"tampered ciphertext", tampered, key},
+		{"truncated", sealed[:10], key},
+		{"not base64", []byte("JWT_SECRET=abc"), key},
+		{"malformed key", sealed, "not-hex"},
+		{"short key", sealed, key[:32]},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := DecryptSecrets(tt.data, tt.key); err == nil {
+				t.Errorf("DecryptSecrets() = %q, want an error", got)
+			}
+		})
+	}
+}
+
+func TestEncryptedFileSecretSourceRereads(t *testing.T) {
+	path, keyFile := writeEncryptedSecrets(t, "JWT_SECRET=first\n")
+	source := NewEncryptedFileSecretSource(path, keyFile)
+
+	if value, ok, err := source.Lookup("JWT_SECRET"); err != nil || !ok || value != "first" {
+		t.Fatalf("Lookup() = %q, %t, %v, want first", value, ok, err)
+	}
+	if _, ok, err := source.Lookup("DB_PASSWORD"); err != nil || ok {
+		t.Errorf("Lookup() of a missing secret = %t, %v, want not found", ok, err)
+	}
+
+	// Rewriting the file with the same key is picked up on the next lookup
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := EncryptSecrets([]byte("JWT_SECRET=second\n"), string(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, sealed, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if value, _, err := source.Lookup("JWT_SECRET"); err != nil || value != "second" {
+		t.Errorf("Lookup() after rewriting the file = %q, %v, want second", value, err)
+	}
+
+	// A corrupted file is reported
+	if err := os.WriteFile(path, []byte("garbage\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := source.Lookup("JWT_SECRET"); err == nil {
+		t.Error("Lookup() of a corrupted file succeeded, want an error")
+	}
+}
+
+func TestManagerReloadSecrets(t *testing.T) {
+	isolateEnv(t)
+	secretFile := writeFile(t, "jwt_secret", testJWTSecret+"\n")
+	t.Setenv("JWT_SECRET_FILE", secretFile)
+	t.Setenv("SESSION_SECRET", testSessionSecret)
+
+	cfg, err := LoadConfiguration(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := NewManager(cfg, nil)
+	var notified [][]Change
+	manager.Subscribe(func(cfg *Config, changes []Change) { notified = append(notified, changes) })
+
+	// Unchanged secrets apply nothing
+	if changes, err := manager.ReloadSecrets(); err != nil || len(changes) != 0 || len(notified) != 0 {
+		t.Fatalf("ReloadSecrets() without rotation = %+v, %v, want no changes", changes, err)
+	}
+
+	// A rotated secret is picked up and announced redacted
+	if err := os.WriteFile(secretFile, []byte(rotatedJWTSecret+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := manager.ReloadSecrets()
+	if err != nil {
+		t.Fatalf("ReloadSecrets() error = %v", err)
+	}
+	want := Change{Setting: "auth.jwt_secret", Old: redactedValue, New: redactedValue, Live: true}
+	if len(changes) != 1 || changes[0] != want {
+		t.Errorf("ReloadSecrets() changes = %+v, want [%+v]", changes, want)
+	}
+	if got := manager.Current().Auth.JWTSecret; got != rotatedJWTSecret {
+		t.Errorf("current jwt secret = %q, want the rotated one", got)
+	}
+	if len(notified) != 1 {
+		t.Errorf("subscribers notified %d times, want once", len(notified))
+	}
+
+	// Rotating to a weak secret is refused and the working one stays
+	for _, weak := range []string{"", "short", strings.Repeat("a", 64)} {
+		if err := os.WriteFile(secretFile, []byte(weak), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := manager.ReloadSecrets(); err == nil {
+			t.Errorf("ReloadSecrets() with %q succeeded, want an error", weak)
+		}
+		if got := manager.Current().Auth.JWTSecret; got != rotatedJWTSecret {
+			t.Errorf("current jwt secret = %q after a refused rotation, want it kept", got)
+		}
+	}
+	if len(notified) != 1 {
+		t.Errorf("subscribers notified %d times, want only for the valid rotation", len(notified))
+	}
+
+	// A secret file that disappears is reported rather than falling back
+	if err := os.Remove(secretFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.ReloadSecrets(); err == nil {
+		t.Error("ReloadSecrets() with a missing secret file succeeded, want an error")
+	}
+}
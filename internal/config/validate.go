@@ -0,0 +1,363 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultJWTSecret and defaultSessionSecret are placeholders that must be replaced outside debug mode
+	defaultJWTSecret     = "your-secret-key"
+	defaultSessionSecret = "your-session-secret"
+
+	// minSecretLength and minSecretEntropyBits apply to secrets outside debug mode
+	minSecretLength      = 32
+	minSecretEntropyBits = 96
+)
+
+// FieldError is a problem with one setting. Field is the config file key
+// (e.g. database.max_idle_conns) or, for malformed variables, the environment variable.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every problem found while loading and validating the configuration
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	lines := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		lines[i] = "\n  - " + fieldErr.Error()
+	}
+	return fmt.Sprintf("%d problems:%s", len(e.Errors), strings.Join(lines, ""))
+}
+
+// add records a problem with a setting
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns e if it holds any problems, nil otherwise
+func (e *ValidationError) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// validateConfig records every invalid or inconsistent setting in problems
+func validateConfig(config *Config, problems *ValidationError) {
+	validateServer(&config.Server, problems)
+	validateDatabase(&config.Database, problems)
+	validateAuth(&config.Auth, config.Debug, problems)
+
+	if config.Secrets.File != "" && config.Secrets.KeyFile == "" {
+		problems.add("secrets.key_file", "required when secrets.file is set")
+	}
+	if config.Secrets.RefreshInterval < 0 {
+		problems.add("secrets.refresh_interval", "must not be negative")
+	}
+
+	if config.Metrics.Listen != "" && !isListenAddress(config.Metrics.Listen) {
+		problems.add("metrics.listen", "%q is not a host:port address", config.Metrics.Listen)
+	} else if config.Metrics.Listen == fmt.Sprintf("%s:%d", config.Server.Host, config.Server.Port) {
+		problems.add("metrics.listen", "must differ from the server address; leave it empty to serve /metrics on the server")
+	}
+
+	if len(config.Auth.ClientCertPrincipals) > 0 && config.Server.TLS.ClientCAFile == "" {
+		problems.add("auth.client_cert_principals", "requires server.tls.client_ca_file")
+	}
+
+	validateTracing(&config.Tracing, problems)
+
+	switch strings.ToLower(config.LogLevel) {
+	case "trace", "debug", "info", "warn", "warning", "error", "fatal", "panic":
+	default:
+		problems.add("log_level", "invalid log level %q", config.LogLevel)
+	}
+}
+
+func validateTracing(tracing *TracingConfig, problems *ValidationError) {
+	switch tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		problems.add("tracing.exporter", "unsupported exporter %q: use none, stdout or otlp", tracing.Exporter)
+	}
+	if tracing.Endpoint != "" && !isAbsoluteURL(tracing.Endpoint) {
+		problems.add("tracing.endpoint", "%q is not an absolute URL", tracing.Endpoint)
+	}
+	if tracing.SampleRatio < 0 || tracing.SampleRatio > 1 {
+		problems.add("tracing.sample_ratio", "must be between 0 and 1")
+	}
+	if tracing.Exporter != "none" && tracing.ServiceName == "" {
+		problems.add("tracing.service_name", "required when tracing is enabled")
+	}
+}
+
+func validateServer(server *ServerConfig, problems *ValidationError) {
+	if server.Port <= 0 || server.Port > 65535 {
+		problems.add("server.port", "invalid port %d", server.Port)
+	}
+	if server.ShutdownDelay < 0 {
+		problems.add("server.shutdown_delay", "must not be negative")
+	}
+	for _, proxy := range server.TrustedProxies {
+		if !isAddressOrPrefix(proxy) {
+			problems.add("server.trusted_proxies", "%q is not an IP address or CIDR range", proxy)
+		}
+	}
+	validateTLS(server, problems)
+}
+
+func validateTLS(server *ServerConfig, problems *ValidationError) {
+	tls := &server.TLS
+	if tls.CertFile != "" && tls.KeyFile == "" {
+		problems.add("server.tls.key_file", "required when server.tls.cert_file is set")
+	}
+	if tls.KeyFile != "" && tls.CertFile == "" {
+		problems.add("server.tls.cert_file", "required when server.tls.key_file is set")
+	}
+	switch tls.MinVersion {
+	case "1.2", "1.3":
+	default:
+		problems.add("server.tls.min_version", "unsupported version %q: use 1.2 or 1.3", tls.MinVersion)
+	}
+	switch tls.CipherPolicy {
+	case "default", "intermediate", "modern":
+	default:
+		problems.add("server.tls.cipher_policy", "unknown policy %q: use default, intermediate or modern", tls.CipherPolicy)
+	}
+	if tls.ReloadInterval < 0 {
+		problems.add("server.tls.reload_interval", "must not be negative")
+	}
+	if tls.HSTSMaxAge < 0 {
+		problems.add("server.tls.hsts_max_age", "must not be negative")
+	}
+	if tls.ClientCAFile != "" && !tls.Enabled() {
+		problems.add("server.tls.client_ca_file", "requires server.tls.cert_file and server.tls.key_file")
+	}
+	if tls.RedirectListen != "" {
+		switch {
+		case !tls.Enabled():
+			problems.add("server.tls.redirect_listen", "requires server.tls.cert_file and server.tls.key_file")
+		case !isListenAddress(tls.RedirectListen):
+			problems.add("server.tls.redirect_listen", "%q is not a host:port address", tls.RedirectListen)
+		case tls.RedirectListen == fmt.Sprintf("%s:%d", server.Host, server.Port):
+			problems.add("server.tls.redirect_listen", "must differ from the server address")
+		}
+	}
+}
+
+func validateDatabase(db *DatabaseConfig, problems *ValidationError) {
+	switch db.Type {
+	case SQLite:
+		if db.Database == "" {
+			problems.add("database.database", "the SQLite database path is required")
+		}
+	case PostgreSQL:
+		if db.Host == "" {
+			problems.add("database.host", "required for PostgreSQL")
+		}
+		if db.Username == "" {
+			problems.add("database.username", "required for PostgreSQL")
+		}
+		if db.Database == "" {
+			problems.add("database.database", "required for PostgreSQL")
+		}
+		if db.Port <= 0 || db.Port > 65535 {
+			problems.add("database.port", "invalid port %d", db.Port)
+		}
+		switch db.SSLMode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			problems.add("database.ssl_mode", "invalid SSL mode %q", db.SSLMode)
+		}
+	default:
+		problems.add("database.type", "invalid database type %q, use %s or %s", db.Type, SQLite, PostgreSQL)
+	}
+
+	// Zero open connections means unlimited
+	if db.MaxOpenConns < 0 {
+		problems.add("database.max_open_conns", "must not be negative")
+	}
+	if db.MaxIdleConns < 0 {
+		problems.add("database.max_idle_conns", "must not be negative")
+	} else if db.MaxOpenConns > 0 && db.MaxIdleConns > db.MaxOpenConns {
+		problems.add("database.max_idle_conns", "%d idle connections exceed the %d open connections allowed",
+			db.MaxIdleConns, db.MaxOpenConns)
+	}
+	if db.ConnMaxLifetime < 0 {
+		problems.add("database.conn_max_lifetime", "must not be negative")
+	}
+}
+
+func validateAuth(auth *AuthConfig, debug bool, problems *ValidationError) {
+	switch auth.AccountLinking {
+	case AccountLinkingNever, AccountLinkingVerifiedEmail:
+	default:
+		problems.add("auth.account_linking", "invalid account linking policy %q", auth.AccountLinking)
+	}
+
+	asymmetric := false
+	switch auth.JWTAlgorithm {
+	case "HS256":
+	case "RS256", "ES256", "EdDSA":
+		asymmetric = true
+	default:
+		problems.add("auth.jwt_algorithm", "invalid JWT algorithm %q", auth.JWTAlgorithm)
+	}
+
+	if auth.JWTExpiresIn <= 0 {
+		problems.add("auth.jwt_expires_in", "must be positive")
+	}
+	if auth.RefreshTokenExpiresIn < auth.JWTExpiresIn {
+		problems.add("auth.refresh_token_expires_in", "must be at least the access token lifetime (%s)", auth.JWTExpiresIn)
+	}
+	if auth.JWTKeyRotationInterval < 0 {
+		problems.add("auth.jwt_key_rotation_interval", "must not be negative")
+	}
+	if auth.JWTKeyOverlap < auth.JWTExpiresIn {
+		problems.add("auth.jwt_key_overlap", "must be at least the access token lifetime (%s)", auth.JWTExpiresIn)
+	}
+
+	// Generated keys live in memory: a restart or a second replica invalidates every token
+	if asymmetric && len(auth.JWTPrivateKeyFiles) == 0 && !debug {
+		problems.add("auth.jwt_private_key_files", "required for %s unless debug is enabled; generated keys don't survive restarts or span replicas", auth.JWTAlgorithm)
+	}
+
+	// Outside debug mode the placeholder secrets would let anyone forge tokens and state cookies
+	if auth.JWTAlgorithm == "HS256" {
+		validateSecret("auth.jwt_secret", auth.JWTSecret, defaultJWTSecret, debug, problems)
+		if debug && auth.RequireAuth && (auth.JWTSecret == "" || auth.JWTSecret == defaultJWTSecret) {
+			problems.add("auth.jwt_secret", "must be set when authentication is required")
+		}
+	}
+	validateSecret("auth.session_secret", auth.SessionSecret, defaultSessionSecret, debug, problems)
+
+	// A provider with only half of its credentials is almost certainly a mistake
+	validateCredentials("auth.google", auth.GoogleClientID, auth.GoogleClientSecret, problems)
+	validateCredentials("auth.github", auth.GitHubClientID, auth.GitHubClientSecret, problems)
+	validateCredentials("auth.gitlab", auth.GitLabClientID, auth.GitLabClientSecret, problems)
+	if auth.OIDCClientID != "" && auth.OIDCIssuerURL == "" {
+		problems.add("auth.oidc_issuer_url", "required when oidc_client_id is set")
+	}
+
+	for _, setting := range []struct{ field, value string }{
+		{"auth.google_redirect_url", auth.GoogleRedirectURL},
+		{"auth.github_redirect_url", auth.GitHubRedirectURL},
+		{"auth.gitlab_redirect_url", auth.GitLabRedirectURL},
+		{"auth.gitlab_base_url", auth.GitLabBaseURL},
+		{"auth.oidc_redirect_url", auth.OIDCRedirectURL},
+		{"auth.oidc_issuer_url", auth.OIDCIssuerURL},
+	} {
+		if setting.value != "" && !isAbsoluteURL(setting.value) {
+			problems.add(setting.field, "%q is not an absolute http(s) URL", setting.value)
+		}
+	}
+
+	for _, email := range auth.AdminEmails {
+		if !strings.Contains(email, "@") {
+			problems.add("auth.admin_emails", "%q is not an email address", email)
+		}
+	}
+
+	identities := make(map[string]bool, len(auth.ClientCertPrincipals))
+	for _, entry := range auth.ClientCertPrincipals {
+		identity, _, err := ParseClientCertPrincipal(entry)
+		if err != nil {
+			problems.add("auth.client_cert_principals", "%v", err)
+		} else if identities[identity] {
+			problems.add("auth.client_cert_principals", "%q is mapped more than once", identity)
+		}
+		identities[identity] = true
+	}
+
+	if auth.RequireAuth && !auth.AnyProviderEnabled() {
+		problems.add("auth.require_auth", "at least one identity provider must be configured when authentication is required")
+	}
+}
+
+// validateSecret rejects placeholder and weak secrets outside debug mode
+func validateSecret(field, secret, placeholder string, debug bool, problems *ValidationError) {
+	if debug {
+		return
+	}
+
+	switch {
+	case secret == "" || secret == placeholder:
+		problems.add(field, "must be set to a random value unless debug is enabled")
+	case len(secret) < minSecretLength:
+		problems.add(field, "must be at least %d characters unless debug is enabled", minSecretLength)
+	case secretEntropyBits(secret) < minSecretEntropyBits:
+		problems.add(field, "is too predictable; use a random value, e.g. from openssl rand -hex 32")
+	}
+}
+
+// validateCredentials requires a provider's client ID and secret to be set together
+func validateCredentials(provider, clientID, clientSecret string, problems *ValidationError) {
+	switch {
+	case clientID != "" && clientSecret == "":
+		problems.add(provider+"_client_secret", "required when %s_client_id is set", strings.TrimPrefix(provider, "auth."))
+	case clientID == "" && clientSecret != "":
+		problems.add(provider+"_client_id", "required when %s_client_secret is set", strings.TrimPrefix(provider, "auth."))
+	}
+}
+
+// secretEntropyBits estimates the entropy of a secret from its character
+// frequencies, so that long but repetitive values are rejected
+func secretEntropyBits(secret string) float64 {
+	counts := map[rune]int{}
+	total := 0
+	for _, r := range secret {
+		counts[r]++
+		total++
+	}
+
+	var bitsPerChar float64
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		bitsPerChar -= p * math.Log2(p)
+	}
+	return bitsPerChar * float64(total)
+}
+
+// isListenAddress reports whether value is a host:port address with a valid port; the host may be empty
+func isListenAddress(value string) bool {
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		return false
+	}
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+// isAddressOrPrefix reports whether value is an IP address or a CIDR range
+func isAddressOrPrefix(value string) bool {
+	if _, err := netip.ParseAddr(value); err == nil {
+		return true
+	}
+	_, err := netip.ParsePrefix(value)
+	return err == nil
+}
+
+// isAbsoluteURL reports whether value is an http or https URL with a host
+func isAbsoluteURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testJWTSecret     = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	testSessionSecret = "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752"
+)
+
+// validConfig returns the defaults with real secrets, which pass validation
+func validConfig() *Config {
+	config := defaultConfig()
+	config.Auth.JWTSecret = testJWTSecret
+	config.Auth.SessionSecret = testSessionSecret
+	return config
+}
+
+// withTLS enables TLS so that the settings depending on it can be tested
+func withTLS(c *Config) {
+	c.Server.TLS.CertFile = "server.crt"
+	c.Server.TLS.KeyFile = "server.key"
+}
+
+// withPostgres switches to a complete PostgreSQL configuration
+func withPostgres(c *Config) {
+	c.Database.Type = PostgreSQL
+	c.Database.Host = "localhost"
+	c.Database.Username = "app"
+	c.Database.Database = "app"
+}
+
+func TestValidateValidConfig(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	debug := defaultConfig()
+	debug.Debug = true
+	if err := debug.Validate(); err != nil {
+		t.Errorf("Validate() of the defaults in debug mode error = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(*Config)
+		wantField string
+	}{
+		// Server
+		{"port out of range", func(c *Config) { c.Server.Port = 70000 }, "server.port"},
+		{"negative shutdown delay", func(c *Config) { c.Server.ShutdownDelay = -time.Second }, "server.shutdown_delay"},
+		{"trusted proxy not an address", func(c *Config) { c.Server.TrustedProxies = []string{"10.0.0.0/8", "proxy.local"} }, "server.trusted_proxies"},
+		{"tls cert without key", func(c *Config) { c.Server.TLS.CertFile = "server.crt" }, "server.tls.key_file"},
+		{"tls key without cert", func(c *Config) { c.Server.TLS.KeyFile = "server.key" }, "server.tls.cert_file"},
+		{"tls min version", func(c *Config) { c.Server.TLS.MinVersion = "1.1" }, "server.tls.min_version"},
+		{"tls cipher policy", func(c *Config) { c.Server.TLS.CipherPolicy = "legacy" }, "server.tls.cipher_policy"},
+		{"negative tls reload interval", func(c *Config) { c.Server.TLS.ReloadInterval = -time.Second }, "server.tls.reload_interval"},
+		{"negative hsts max age", func(c *Config) { c.Server.TLS.HSTSMaxAge = -time.Second }, "server.tls.hsts_max_age"},
+		{"client ca without tls", func(c *Config) { c.Server.TLS.ClientCAFile = "ca.crt" }, "server.tls.client_ca_file"},
+		{"redirect without tls", func(c *Config) { c.Server.TLS.RedirectListen = ":80" }, "server.tls.redirect_listen"},
+		{"redirect not an address", func(c *Config) { withTLS(c); c.Server.TLS.RedirectListen = "http" }, "server.tls.redirect_listen"},
+		{"redirect on the server address", func(c *Config) { withTLS(c); c.Server.TLS.RedirectListen = "0.0.0.0:8080" }, "server.tls.redirect_listen"},
+
+		// Database
+		{"database type", func(c *Config) { c.Database.Type = "mysql" }, "database.type"},
+		{"sqlite without path", func(c *Config) { c.Database.Database = "" }, "database.database"},
+		{"postgres without host", func(c *Config) { withPostgres(c); c.Database.Host = "" }, "database.host"},
+		{"postgres without username", func(c *Config) { withPostgres(c); c.Database.Username = "" }, "database.username"},
+		{"postgres without database", func(c *Config) { withPostgres(c); c.Database.Database = "" }, "database.database"},
+		{"postgres port out of range", func(c *Config) { withPostgres(c); c.Database.Port = 0 }, "database.port"},
+		{"postgres ssl mode", func(c *Config) { withPostgres(c); c.Database.SSLMode = "on" }, "database.ssl_mode"},
+		{"negative max open conns", func(c *Config) { c.Database.MaxOpenConns = -1 }, "database.max_open_conns"},
+		{"negative max idle conns", func(c *Config) { c.Database.MaxIdleConns = -1 }, "database.max_idle_conns"},
+		{"more idle than open conns", func(c *Config) { c.Database.MaxIdleConns = 30 }, "database.max_idle_conns"},
+		{"negative conn max lifetime", func(c *Config) { c.Database.ConnMaxLifetime = -time.Second }, "database.conn_max_lifetime"},
+
+		// Auth
+		{"account linking", func(c *Config) { c.Auth.AccountLinking = "always" }, "auth.account_linking"},
+		{"jwt algorithm", func(c *Config) { c.Auth.JWTAlgorithm = "none" }, "auth.jwt_algorithm"},
+		{"jwt lifetime not positive", func(c *Config) { c.Auth.JWTExpiresIn = 0 }, "auth.jwt_expires_in"},
+		{"refresh shorter than access token", func(c *Config) { c.Auth.RefreshTokenExpiresIn = time.Minute }, "auth.refresh_token_expires_in"},
+		{"negative key rotation interval", func(c *Config) { c.Auth.JWTKeyRotationInterval = -time.Hour }, "auth.jwt_key_rotation_interval"},
+		{"key overlap shorter than access token", func(c *Config) { c.Auth.JWTKeyOverlap = time.Minute }, "auth.jwt_key_overlap"},
+		{"asymmetric algorithm without key files", func(c *Config) { c.Auth.JWTAlgorithm = "RS256" }, "auth.jwt_private_key_files"},
+		{"placeholder jwt secret", func(c *Config) { c.Auth.JWTSecret = defaultJWTSecret }, "auth.jwt_secret"},
+		{"short jwt secret", func(c *Config) { c.Auth.JWTSecret = "9f86d081884c7d65" }, "auth.jwt_secret"},
+		{"predictable jwt secret", func(c *Config) { c.Auth.JWTSecret = strings.Repeat("ab", 32) }, "auth.jwt_secret"},
+		{"placeholder jwt secret with required auth in debug", func(c *Config) {
+			c.Debug = true
+			c.Auth.RequireAuth = true
+			c.Auth.JWTSecret = defaultJWTSecret
+			c.Auth.GoogleClientID, c.Auth.GoogleClientSecret = "client-id", "client-secret"
+		}, "auth.jwt_secret"},
+		{"placeholder session secret", func(c *Config) { c.Auth.SessionSecret = defaultSessionSecret }, "auth.session_secret"},
+		{"client id without secret", func(c *Config) { c.Auth.GoogleClientID = "client-id" }, "auth.google_client_secret"},
+		{"client secret without id", func(c *Config) { c.Auth.GitHubClientSecret = "client-secret" }, "auth.github_client_id"},
+		{"oidc without issuer", func(c *Config) { c.Auth.OIDCClientID = "client-id" }, "auth.oidc_issuer_url"},
+		{"relative redirect url", func(c *Config) { c.Auth.GoogleRedirectURL = "/auth/google/callback" }, "auth.google_redirect_url"},
+		{"admin email", func(c *Config) { c.Auth.AdminEmails = []string{"admin"} }, "auth.admin_emails"},
+		{"client cert principal without ca", func(c *Config) {
+			c.Auth.ClientCertPrincipals = []string{"cn:billing=billing@example.com"}
+		}, "auth.client_cert_principals"},
+		{"malformed client cert principal", func(c *Config) {
+			withTLS(c)
+			c.Server.TLS.ClientCAFile = "ca.crt"
+			c.Auth.ClientCertPrincipals = []string{"billing@example.com"}
+		}, "auth.client_cert_principals"},
+		{"duplicate client cert principal", func(c *Config) {
+			withTLS(c)
+			c.Server.TLS.ClientCAFile = "ca.crt"
+			c.Auth.ClientCertPrincipals = []string{"cn:billing=billing@example.com", "CN:billing=other@example.com"}
+		}, "auth.client_cert_principals"},
+		{"required auth without providers", func(c *Config) { c.Auth.RequireAuth = true }, "auth.require_auth"},
+
+		// Secrets, metrics, tracing and logging
+		{"secrets file without key", func(c *Config) { c.Secrets.File = "secrets.enc" }, "secrets.key_file"},
+		{"negative secrets refresh interval", func(c *Config) { c.Secrets.RefreshInterval = -time.Second }, "secrets.refresh_interval"},
+		{"metrics listen not an address", func(c *Config) { c.Metrics.Listen = "metrics" }, "metrics.listen"},
+		{"metrics on the server address", func(c *Config) { c.Metrics.Listen = "0.0.0.0:8080" }, "metrics.listen"},
+		{"tracing exporter", func(c *Config) { c.Tracing.Exporter = "jaeger" }, "tracing.exporter"},
+		{"relative tracing endpoint", func(c *Config) { c.Tracing.Endpoint = "collector:4318" }, "tracing.endpoint"},
+		{"tracing sample ratio", func(c *Config) { c.Tracing.SampleRatio = 1.5 }, "tracing.sample_ratio"},
+		{"tracing without service name", func(c *Config) { c.Tracing.Exporter = "stdout"; c.Tracing.ServiceName = "" }, "tracing.service_name"},
+		{"log level", func(c *Config) { c.LogLevel = "verbose" }, "log_level"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig()
+			tt.modify(config)
+
+			var problems *ValidationError
+			if err := config.Validate(); !errors.As(err, &problems) {
+				t.Fatalf("Validate() error = %v, want a *ValidationError", err)
+			}
+			if len(problems.Errors) != 1 || problems.Errors[0].Field != tt.wantField {
+				t.Errorf("Validate() problems = %+v, want one for %s", problems.Errors, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	config := validConfig()
+	config.Server.Port = 0
+	config.Database.Type = "mysql"
+	config.Auth.JWTSecret = ""
+	config.Tracing.SampleRatio = -1
+	config.LogLevel = "verbose"
+
+	var problems *ValidationError
+	if err := config.Validate(); !errors.As(err, &problems) {
+		t.Fatalf("Validate() error = %v, want a *ValidationError", err)
+	}
+
+	wantFields := []string{"server.port", "database.type", "auth.jwt_secret", "tracing.sample_ratio", "log_level"}
+	if len(problems.Errors) != len(wantFields) {
+		t.Fatalf("Validate() problems = %+v, want %d", problems.Errors, len(wantFields))
+	}
+	for i, field := range wantFields {
+		if problems.Errors[i].Field != field {
+			t.Errorf("problem %d is for %s, want %s", i, problems.Errors[i].Field, field)
+		}
+	}
+
+	message := problems.Error()
+	if !strings.HasPrefix(message, "5 problems:") {
+		t.Errorf("Error() = %q, want it to count the 5 problems", message)
+	}
+	for _, field := range wantFields {
+		if !strings.Contains(message, "\n  - "+field+": ") {
+			t.Errorf("Error() = %q, want a line for %s", message, field)
+		}
+	}
+}
+
+func TestLoadConfigurationReportsAllProblems(t *testing.T) {
+	isolateEnv(t)
+	t.Setenv("SERVER_PORT", "eighty")
+	path := writeFile(t, "config.yaml", "log_levle: debug\ndatabase:\n  type: mysql\n")
+
+	// Malformed values, unknown keys and invalid settings are reported together
+	_, err := LoadConfiguration([]string{"-config", path})
+	var problems *ValidationError
+	if !errors.As(err, &problems) {
+		t.Fatalf("LoadConfiguration() error = %v, want a *ValidationError", err)
+	}
+	if !strings.HasPrefix(err.Error(), "configuration validation failed: ") {
+		t.Errorf("LoadConfiguration() error = %q", err)
+	}
+
+	fields := map[string]bool{}
+	for _, problem := range problems.Errors {
+		fields[problem.Field] = true
+	}
+	for _, field := range []string{"log_levle", "SERVER_PORT", "database.type", "auth.jwt_secret", "auth.session_secret"} {
+		if !fields[field] {
+			t.Errorf("LoadConfiguration() problems = %+v, want one for %s", problems.Errors, field)
+		}
+	}
+}
@@ -1,25 +1,37 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"sync"
 
 	"webui-skeleton/internal/config"
 	"webui-skeleton/internal/logger"
 
-	_ "github.com/lib/pq"
+	"github.com/XSAM/otelsql"
+	"github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 )
 
 type DB struct {
-	DB     *sql.DB
-	config *config.DatabaseConfig
+	DB      *sql.DB
+	config  *config.DatabaseConfig
+	dialect Dialect
+
+	// password may be rotated while connected; new connections use the current one
+	mu       sync.RWMutex
+	password string
 }
 
 // New creates a new database instance
 func New(config *config.DatabaseConfig) *DB {
 	return &DB{
-		config: config,
+		config:   config,
+		dialect:  NewDialect(config.Type),
+		password: config.Password,
 	}
 }
 
@@ -32,9 +44,17 @@ func (db *DB) Connect() error {
 		Str("database", db.config.Database).
 		Msg("Connecting to database")
 
-	db.DB, err = sql.Open(db.config.GetDriverName(), db.config.GetDSN())
-	if err != nil {
-		return fmt.Errorf("failed to open database connection: %w", err)
+	// Queries are traced; those run with a request context (ExecContext, QueryContext, ...) join its trace
+	if db.config.Type == config.PostgreSQL {
+		// Build the DSN per connection so a rotated password applies without reconnecting
+		db.DB = otelsql.OpenDB(&passwordConnector{db: db, driver: &pq.Driver{}},
+			otelsql.WithAttributes(semconv.DBSystemNamePostgreSQL))
+	} else {
+		db.DB, err = otelsql.Open(db.config.GetDriverName(), db.config.GetDSN(),
+			otelsql.WithAttributes(semconv.DBSystemNameSQLite))
+		if err != nil {
+			return fmt.Errorf("failed to open database connection: %w", err)
+		}
 	}
 
 	// Configure connection pool
@@ -51,75 +71,42 @@ func (db *DB) Connect() error {
 	return nil
 }
 
-// Close closes the database connection
-func (db *DB) Close() error {
-	if db.DB != nil {
-		logger.Log.Info().Msg("Closing database connection")
-		return db.DB.Close()
-	}
-	return nil
+// SetPassword changes the password used for new connections; open connections are kept
+func (db *DB) SetPassword(password string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.password = password
 }
 
-// Migrate runs database migrations
-func (db *DB) Migrate() error {
-	logger.Log.Info().Msg("Running database migrations")
-
-	// Create users table
-	usersSQL := `
-		CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			google_id VARCHAR(255) UNIQUE NOT NULL,
-			email VARCHAR(255) UNIQUE NOT NULL,
-			name VARCHAR(255) NOT NULL,
-			picture VARCHAR(500),
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`
+// dsn returns the data source name with the current password
+func (db *DB) dsn() string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
 
-	if db.config.Type == config.PostgreSQL {
-		usersSQL = `
-			CREATE TABLE IF NOT EXISTS users (
-				id SERIAL PRIMARY KEY,
-				google_id VARCHAR(255) UNIQUE NOT NULL,
-				email VARCHAR(255) UNIQUE NOT NULL,
-				name VARCHAR(255) NOT NULL,
-				picture VARCHAR(500),
-				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-			)`
-	}
+	cfg := *db.config
+	cfg.Password = db.password
+	return cfg.GetDSN()
+}
 
-	if _, err := db.DB.Exec(usersSQL); err != nil {
-		return fmt.Errorf("failed to create users table: %w", err)
-	}
+// passwordConnector opens connections with the DSN of the current password
+type passwordConnector struct {
+	db     *DB
+	driver driver.Driver
+}
 
-	// Create sessions table for session management
-	sessionsSQL := `
-		CREATE TABLE IF NOT EXISTS sessions (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			token VARCHAR(500) NOT NULL,
-			expires_at DATETIME NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-		)`
+func (c *passwordConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.db.dsn())
+}
 
-	if db.config.Type == config.PostgreSQL {
-		sessionsSQL = `
-			CREATE TABLE IF NOT EXISTS sessions (
-				id SERIAL PRIMARY KEY,
-				user_id INTEGER NOT NULL,
-				token VARCHAR(500) NOT NULL,
-				expires_at TIMESTAMP NOT NULL,
-				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-			)`
-	}
+func (c *passwordConnector) Driver() driver.Driver {
+	return c.driver
+}
 
-	if _, err := db.DB.Exec(sessionsSQL); err != nil {
-		return fmt.Errorf("failed to create sessions table: %w", err)
+// Close closes the database connection
+func (db *DB) Close() error {
+	if db.DB != nil {
+		logger.Log.Info().Msg("Closing database connection")
+		return db.DB.Close()
 	}
-
-	logger.Log.Info().Msg("✅ Database migrations completed")
 	return nil
 }
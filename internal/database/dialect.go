@@ -0,0 +1,99 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+
+	"webui-skeleton/internal/config"
+)
+
+// Dialect hides the SQL differences between the supported databases.
+// Queries are written with ? placeholders and rebound for the target database.
+type Dialect interface {
+	// Name returns the database type the dialect targets
+	Name() config.DatabaseType
+
+	// Rebind rewrites the ? placeholders of a query into the dialect's style
+	Rebind(query string) string
+
+	// SupportsReturning reports whether inserted IDs are read with RETURNING
+	// instead of sql.Result.LastInsertId
+	SupportsReturning() bool
+
+	// Upsert returns the clause that turns an INSERT into an upsert on conflictColumns.
+	// With no updateColumns, conflicting rows are left untouched.
+	Upsert(conflictColumns []string, updateColumns ...string) string
+
+	// TimestampType is the column type for timestamps
+	TimestampType() string
+}
+
+// NewDialect returns the dialect for a database type
+func NewDialect(dbType config.DatabaseType) Dialect {
+	if dbType == config.PostgreSQL {
+		return postgresDialect{}
+	}
+	return sqliteDialect{}
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() config.DatabaseType  { return config.SQLite }
+func (sqliteDialect) Rebind(query string) string { return query }
+func (sqliteDialect) SupportsReturning() bool    { return false }
+func (sqliteDialect) TimestampType() string      { return "DATETIME" }
+
+func (sqliteDialect) Upsert(conflictColumns []string, updateColumns ...string) string {
+	return onConflict(conflictColumns, updateColumns)
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() config.DatabaseType { return config.PostgreSQL }
+func (postgresDialect) SupportsReturning() bool   { return true }
+func (postgresDialect) TimestampType() string     { return "TIMESTAMP" }
+
+func (postgresDialect) Upsert(conflictColumns []string, updateColumns ...string) string {
+	return onConflict(conflictColumns, updateColumns)
+}
+
+// Rebind numbers the placeholders ($1, $2, ...), leaving quoted strings and identifiers alone
+func (postgresDialect) Rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	var quote rune
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// onConflict builds the ON CONFLICT clause shared by SQLite (3.24+) and PostgreSQL
+func onConflict(conflictColumns, updateColumns []string) string {
+	clause := " ON CONFLICT (" + strings.Join(conflictColumns, ", ") + ")"
+	if len(updateColumns) == 0 {
+		return clause + " DO NOTHING"
+	}
+
+	sets := make([]string, len(updateColumns))
+	for i, column := range updateColumns {
+		sets[i] = column + " = excluded." + column
+	}
+	return clause + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
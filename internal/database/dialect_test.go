@@ -0,0 +1,66 @@
+package database
+
+import (
+	"testing"
+
+	"webui-skeleton/internal/config"
+)
+
+func TestPostgresRebind(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"no placeholders", `SELECT 1`, `SELECT 1`},
+		{"one placeholder", `SELECT * FROM users WHERE id = ?`, `SELECT * FROM users WHERE id = $1`},
+		{"several placeholders", `UPDATE users SET name = ?, picture = ? WHERE id = ?`, `UPDATE users SET name = $1, picture = $2 WHERE id = $3`},
+		{"adjacent placeholders", `VALUES (?,?)`, `VALUES ($1,$2)`},
+		{"ten or more", `IN (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, `IN ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`},
+		{"inside a string", `SELECT '?' WHERE id = ?`, `SELECT '?' WHERE id = $1`},
+		{"inside a quoted identifier", `SELECT "what?" FROM t WHERE id = ?`, `SELECT "what?" FROM t WHERE id = $1`},
+		{"escaped quote in a string", `SELECT 'it''s ?' WHERE a = ? AND b = 'x?'`, `SELECT 'it''s ?' WHERE a = $1 AND b = 'x?'`},
+		{"double quote inside a string", `SELECT '"?' WHERE id = ?`, `SELECT '"?' WHERE id = $1`},
+		{"single quote inside an identifier", `SELECT "a'?" WHERE id = ?`, `SELECT "a'?" WHERE id = $1`},
+		{"non-ASCII text", `SELECT 'héllo' WHERE name = ?`, `SELECT 'héllo' WHERE name = $1`},
+	}
+
+	dialect := NewDialect(config.PostgreSQL)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dialect.Rebind(tt.query); got != tt.want {
+				t.Errorf("Rebind(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteRebind(t *testing.T) {
+	query := `SELECT '?' WHERE a = ? AND b = ?`
+	if got := NewDialect(config.SQLite).Rebind(query); got != query {
+		t.Errorf("Rebind(%q) = %q, want the query unchanged", query, got)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	tests := []struct {
+		name            string
+		conflictColumns []string
+		updateColumns   []string
+		want            string
+	}{
+		{"do nothing", []string{"name"}, nil, ` ON CONFLICT (name) DO NOTHING`},
+		{"composite key", []string{"user_id", "role_id"}, nil, ` ON CONFLICT (user_id, role_id) DO NOTHING`},
+		{"update", []string{"email"}, []string{"name", "picture"}, ` ON CONFLICT (email) DO UPDATE SET name = excluded.name, picture = excluded.picture`},
+	}
+
+	for _, dbType := range []config.DatabaseType{config.SQLite, config.PostgreSQL} {
+		for _, tt := range tests {
+			t.Run(string(dbType)+"/"+tt.name, func(t *testing.T) {
+				if got := NewDialect(dbType).Upsert(tt.conflictColumns, tt.updateColumns...); got != tt.want {
+					t.Errorf("Upsert() = %q, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
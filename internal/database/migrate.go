@@ -0,0 +1,501 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"webui-skeleton/internal/config"
+	"webui-skeleton/internal/logger"
+)
+
+// embeddedMigrations holds the migrations, named NNNN_name[.dialect].(up|down).sql
+//
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// migrationFiles is where migrations are loaded from; tests substitute their own
+var migrationFiles fs.FS = embeddedMigrations
+
+// migrationLockID is the PostgreSQL advisory lock key held while migrating
+const migrationLockID = 7423411
+
+// migrationLockTimeout is how long SQLite waits for another process's migrations to finish
+const migrationLockTimeout = 5 * time.Minute
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+?)(?:\.(sqlite|postgresql))?\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationInfo reports whether a migration has been applied
+type MigrationInfo struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// appliedMigration is a row of the schema_migrations table
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// migrationFile collects the up and down SQL of a migration while loading
+type migrationFile struct {
+	name     string
+	up, down string
+}
+
+// loadMigrations reads the embedded migrations for a dialect, ordered by version
+func loadMigrations(dialect config.DatabaseType) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	files := map[int]*migrationFile{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+
+		fileDialect := config.DatabaseType(match[3])
+		if fileDialect != "" && fileDialect != dialect {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		file, ok := files[version]
+		if !ok {
+			file = &migrationFile{name: match[2]}
+			files[version] = file
+		} else if file.name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, file.name, match[2])
+		}
+
+		content, err := fs.ReadFile(migrationFiles, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		// A dialect-specific file replaces the generic one
+		specific := fileDialect != ""
+		if match[4] == "up" {
+			if file.up == "" || specific {
+				file.up = string(content)
+			}
+		} else if file.down == "" || specific {
+			file.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(files))
+	for version, file := range files {
+		if file.up == "" {
+			return nil, fmt.Errorf("migration %d has no up migration for %s", version, dialect)
+		}
+		sum := sha256.Sum256([]byte(file.up))
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     file.name,
+			Up:       file.up,
+			Down:     file.down,
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrate applies every pending migration, each in its own transaction.
+// It refuses to run against a database migrated by a newer binary or whose
+// applied migrations no longer match the embedded files.
+func (db *DB) Migrate() error {
+	logger.Log.Info().Msg("Running database migrations")
+
+	return db.withMigrationLock(func(ctx context.Context, conn *sql.Conn, migrations []Migration, applied map[int]appliedMigration) error {
+		pending := 0
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			if migration.Version == migrations[0].Version && len(applied) == 0 {
+				if err := db.adoptLegacySchema(ctx, conn); err != nil {
+					return err
+				}
+			}
+
+			err := db.runInTx(ctx, conn, migration.Up,
+				`INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)`,
+				migration.Version, migration.Name, migration.Checksum)
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			logger.Log.Info().Int("version", migration.Version).Str("name", migration.Name).Msg("Applied migration")
+			pending++
+		}
+
+		logger.Log.Info().Int("applied", pending).Msg("✅ Database migrations completed")
+		return nil
+	})
+}
+
+// Rollback reverts the last steps applied migrations using their down files
+func (db *DB) Rollback(steps int) error {
+	return db.withMigrationLock(func(ctx context.Context, conn *sql.Conn, migrations []Migration, applied map[int]appliedMigration) error {
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %04d_%s has no down migration", migration.Version, migration.Name)
+			}
+
+			err := db.runInTx(ctx, conn, migration.Down,
+				`DELETE FROM schema_migrations WHERE version = ?`, migration.Version)
+			if err != nil {
+				return fmt.Errorf("failed to roll back migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			logger.Log.Info().Int("version", migration.Version).Str("name", migration.Name).Msg("Rolled back migration")
+			steps--
+		}
+		return nil
+	})
+}
+
+// MigrationStatus lists the embedded migrations and whether each has been applied.
+// Like CheckMigrations it only reads: it takes no lock and creates nothing.
+func (db *DB) MigrationStatus() ([]MigrationInfo, error) {
+	migrations, err := loadMigrations(db.dialect.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	conn, err := db.DB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	// A database that was never migrated has no schema_migrations table yet
+	applied := map[int]appliedMigration{}
+	exists, err := db.tableExists(ctx, conn, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		if applied, err = db.appliedMigrations(ctx, conn); err != nil {
+			return nil, err
+		}
+		if err := checkAppliedMigrations(migrations, applied); err != nil {
+			return nil, err
+		}
+	}
+
+	status := make([]MigrationInfo, 0, len(migrations))
+	for _, migration := range migrations {
+		info := MigrationInfo{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.appliedAt
+			info.Applied, info.AppliedAt = true, &appliedAt
+		}
+		status = append(status, info)
+	}
+	return status, nil
+}
+
+// CheckMigrations fails when migrations are pending or the applied ones don't match
+// this binary. Unlike MigrationStatus it takes no lock, so it is cheap enough for probes.
+func (db *DB) CheckMigrations(ctx context.Context) error {
+	migrations, err := loadMigrations(db.dialect.Name())
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	applied, err := db.appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if err := checkAppliedMigrations(migrations, applied); err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d of %d migrations pending", pending, len(migrations))
+	}
+	return nil
+}
+
+// withMigrationLock runs fn on a dedicated connection once the schema_migrations table
+// exists and the applied migrations have been checked against the embedded ones.
+// The connection holds a lock so that processes starting together migrate one at a time.
+func (db *DB) withMigrationLock(fn func(ctx context.Context, conn *sql.Conn, migrations []Migration, applied map[int]appliedMigration) error) (err error) {
+	migrations, err := loadMigrations(db.dialect.Name())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, err := db.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	unlock, err := db.lockMigrations(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = fmt.Errorf("failed to release migration lock: %w", unlockErr)
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum VARCHAR(64) NOT NULL,
+			applied_at `+db.dialect.TimestampType()+` DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	applied, err := db.appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	if err := checkAppliedMigrations(migrations, applied); err != nil {
+		return err
+	}
+
+	return fn(ctx, conn, migrations, applied)
+}
+
+// appliedMigrations reads the schema_migrations table keyed by version
+func (db *DB) appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedMigration{}
+	for rows.Next() {
+		var version int
+		var row appliedMigration
+		if err := rows.Scan(&version, &row.name, &row.checksum, &row.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		applied[version] = row
+	}
+
+	return applied, rows.Err()
+}
+
+// checkAppliedMigrations fails when the database holds migrations this binary
+// doesn't know about, or when an applied migration's file has since been edited
+func checkAppliedMigrations(migrations []Migration, applied map[int]appliedMigration) error {
+	known := make(map[int]Migration, len(migrations))
+	latest := 0
+	for _, migration := range migrations {
+		known[migration.Version] = migration
+		latest = migration.Version
+	}
+
+	for version, row := range applied {
+		migration, ok := known[version]
+		switch {
+		case !ok && version > latest:
+			return fmt.Errorf("database schema version %d is newer than this binary (latest %d); upgrade the binary", version, latest)
+		case !ok:
+			return fmt.Errorf("applied migration %04d_%s is unknown to this binary", version, row.name)
+		case migration.Checksum != row.checksum:
+			return fmt.Errorf("migration %04d_%s was modified after it was applied", version, migration.Name)
+		}
+	}
+
+	return nil
+}
+
+// lockMigrations takes the migration lock on conn and returns the function releasing it.
+// PostgreSQL uses an advisory lock. SQLite has none, so the whole run happens in an
+// IMMEDIATE transaction, which holds the database's write lock from the start; other
+// processes wait for it up to migrationLockTimeout.
+func (db *DB) lockMigrations(ctx context.Context, conn *sql.Conn) (func() error, error) {
+	if db.dialect.Name() == config.PostgreSQL {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+			return nil, err
+		}
+		return func() error {
+			_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+			return err
+		}, nil
+	}
+
+	var busyTimeout int
+	if err := conn.QueryRowContext(ctx, `PRAGMA busy_timeout`).Scan(&busyTimeout); err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`PRAGMA busy_timeout = %d`, migrationLockTimeout.Milliseconds())); err != nil {
+		return nil, err
+	}
+	// The connection goes back to the pool afterwards, with its usual timeout
+	restoreTimeout := func() {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf(`PRAGMA busy_timeout = %d`, busyTimeout)); err != nil {
+			logger.Log.Warn().Err(err).Msg("Failed to restore the SQLite busy timeout")
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		restoreTimeout()
+		return nil, err
+	}
+	return func() error {
+		defer restoreTimeout()
+		// Migrations that failed were already rolled back to their savepoint
+		if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+			conn.ExecContext(ctx, `ROLLBACK`)
+			return err
+		}
+		return nil
+	}, nil
+}
+
+// runInTx executes a migration script and its schema_migrations bookkeeping atomically:
+// in its own transaction on PostgreSQL, and in a savepoint of the lock's transaction on SQLite
+func (db *DB) runInTx(ctx context.Context, conn *sql.Conn, script, bookkeeping string, args ...interface{}) error {
+	if db.dialect.Name() == config.PostgreSQL {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if err := db.runScript(ctx, tx, script, bookkeeping, args...); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	if _, err := conn.ExecContext(ctx, `SAVEPOINT migration`); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	if err := db.runScript(ctx, conn, script, bookkeeping, args...); err != nil {
+		if _, rollbackErr := conn.ExecContext(ctx, `ROLLBACK TO migration`); rollbackErr != nil {
+			return fmt.Errorf("%w (and failed to roll back: %v)", err, rollbackErr)
+		}
+		conn.ExecContext(ctx, `RELEASE migration`)
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, `RELEASE migration`); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+// runScript executes a migration script followed by its schema_migrations bookkeeping
+func (db *DB) runScript(ctx context.Context, runner sqlRunner, script, bookkeeping string, args ...interface{}) error {
+	if _, err := runner.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := runner.ExecContext(ctx, db.dialect.Rebind(bookkeeping), args...); err != nil {
+		return fmt.Errorf("failed to update schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// adoptLegacySchema brings a database created before versioned migrations up to
+// the baseline, so that the first migration's CREATE TABLE IF NOT EXISTS
+// statements find the tables in the shape they expect
+func (db *DB) adoptLegacySchema(ctx context.Context, conn *sql.Conn) error {
+	exists, err := db.tableExists(ctx, conn, "users")
+	if err != nil || !exists {
+		return err
+	}
+
+	logger.Log.Info().Msg("Adopting existing database schema")
+	for _, column := range []string{"disabled_at", "deleted_at"} {
+		if err := db.addColumnIfMissing(ctx, conn, "users", column, db.dialect.TimestampType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableExists reports whether a table is present in the current schema
+func (db *DB) tableExists(ctx context.Context, conn *sql.Conn, table string) (bool, error) {
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+	if db.dialect.Name() == config.PostgreSQL {
+		query = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?`
+	}
+
+	var count int
+	if err := conn.QueryRowContext(ctx, db.dialect.Rebind(query), table).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to look up %s table: %w", table, err)
+	}
+	return count > 0, nil
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already there
+func (db *DB) addColumnIfMissing(ctx context.Context, conn *sql.Conn, table, column, definition string) error {
+	if db.dialect.Name() == config.PostgreSQL {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s`, table, column, definition)); err != nil {
+			return fmt.Errorf("failed to add %s.%s column: %w", table, column, err)
+		}
+		return nil
+	}
+
+	var count int
+	if err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count); err != nil {
+		return fmt.Errorf("failed to inspect %s table: %w", table, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s column: %w", table, column, err)
+	}
+	return nil
+}
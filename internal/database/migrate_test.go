@@ -0,0 +1,268 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"webui-skeleton/internal/config"
+)
+
+// useMigrations replaces the embedded migrations with files for the rest of the test
+func useMigrations(t *testing.T, files map[string]string) {
+	t.Helper()
+	mapFS := fstest.MapFS{}
+	for name, content := range files {
+		mapFS["migrations/"+name] = &fstest.MapFile{Data: []byte(content)}
+	}
+
+	previous := migrationFiles
+	migrationFiles = mapFS
+	t.Cleanup(func() { migrationFiles = previous })
+}
+
+// connectSQLite opens the SQLite database at path like the server does
+func connectSQLite(t *testing.T, path string) *DB {
+	t.Helper()
+	db := New(&config.DatabaseConfig{Type: config.SQLite, Database: path})
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func appliedVersions(t *testing.T, db *DB) []int {
+	t.Helper()
+	rows, err := db.Query(`SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+func TestMigrateEmbedded(t *testing.T) {
+	db := connectSQLite(t, filepath.Join(t.TempDir(), "test.db"))
+	migrations, err := loadMigrations(config.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.CheckMigrations(context.Background()); err == nil {
+		t.Error("CheckMigrations() on an empty database succeeded, want an error")
+	}
+
+	for range 2 {
+		if err := db.Migrate(); err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+	}
+	if got := appliedVersions(t, db); len(got) != len(migrations) {
+		t.Errorf("applied versions = %v, want %d migrations", got, len(migrations))
+	}
+	if err := db.CheckMigrations(context.Background()); err != nil {
+		t.Errorf("CheckMigrations() error = %v", err)
+	}
+
+	if err := db.Rollback(1); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if got := appliedVersions(t, db); len(got) != len(migrations)-1 {
+		t.Errorf("applied versions after rollback = %v, want %d migrations", got, len(migrations)-1)
+	}
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate() after rollback error = %v", err)
+	}
+}
+
+func TestMigrationStatus(t *testing.T) {
+	useMigrations(t, map[string]string{
+		"0001_first.up.sql":  `CREATE TABLE first (id INTEGER PRIMARY KEY)`,
+		"0002_second.up.sql": `CREATE TABLE second (id INTEGER PRIMARY KEY)`,
+	})
+	db := connectSQLite(t, filepath.Join(t.TempDir(), "test.db"))
+
+	// On a new database it reports every migration as pending without creating anything
+	status, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus() error = %v", err)
+	}
+	if len(status) != 2 || status[0].Applied || status[1].Applied {
+		t.Errorf("MigrationStatus() = %+v, want two pending migrations", status)
+	}
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Error("MigrationStatus() created the schema_migrations table")
+	}
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	status, err = db.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus() error = %v", err)
+	}
+	for _, info := range status {
+		if !info.Applied || info.AppliedAt == nil {
+			t.Errorf("migration %d = %+v, want applied", info.Version, info)
+		}
+	}
+}
+
+func TestMigrateRejectsMismatchedDatabase(t *testing.T) {
+	baseline := map[string]string{
+		"0001_first.up.sql":    `CREATE TABLE first (id INTEGER PRIMARY KEY)`,
+		"0001_first.down.sql":  `DROP TABLE first`,
+		"0002_second.up.sql":   `CREATE TABLE second (id INTEGER PRIMARY KEY)`,
+		"0002_second.down.sql": `DROP TABLE second`,
+	}
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name: "edited migration",
+			files: map[string]string{
+				"0001_first.up.sql":  `CREATE TABLE first (id INTEGER PRIMARY KEY, name TEXT)`,
+				"0002_second.up.sql": baseline["0002_second.up.sql"],
+			},
+			wantErr: "migration 0001_first was modified after it was applied",
+		},
+		{
+			name: "database ahead of the binary",
+			files: map[string]string{
+				"0001_first.up.sql": baseline["0001_first.up.sql"],
+			},
+			wantErr: "database schema version 2 is newer than this binary (latest 1)",
+		},
+		{
+			name: "unknown migration",
+			files: map[string]string{
+				"0001_first.up.sql": baseline["0001_first.up.sql"],
+				"0003_third.up.sql": `CREATE TABLE third (id INTEGER PRIMARY KEY)`,
+			},
+			wantErr: "applied migration 0002_second is unknown to this binary",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := connectSQLite(t, filepath.Join(t.TempDir(), "test.db"))
+			useMigrations(t, baseline)
+			if err := db.Migrate(); err != nil {
+				t.Fatal(err)
+			}
+
+			useMigrations(t, tt.files)
+			checks := map[string]func() error{
+				"Migrate":         db.Migrate,
+				"Rollback":        func() error { return db.Rollback(1) },
+				"CheckMigrations": func() error { return db.CheckMigrations(context.Background()) },
+				"MigrationStatus": func() error { _, err := db.MigrationStatus(); return err },
+			}
+			for name, check := range checks {
+				if err := check(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("%s() error = %v, want %q", name, err, tt.wantErr)
+				}
+			}
+
+			if got := appliedVersions(t, db); len(got) != 2 {
+				t.Errorf("applied versions = %v, want the database left alone", got)
+			}
+		})
+	}
+}
+
+func TestMigrateRollsBackFailingMigration(t *testing.T) {
+	db := connectSQLite(t, filepath.Join(t.TempDir(), "test.db"))
+	useMigrations(t, map[string]string{
+		"0001_first.up.sql": `CREATE TABLE first (id INTEGER PRIMARY KEY)`,
+		// The second statement fails after the first has run
+		"0002_second.up.sql": `CREATE TABLE second (id INTEGER PRIMARY KEY); INSERT INTO missing VALUES (1);`,
+		"0003_third.up.sql":  `CREATE TABLE third (id INTEGER PRIMARY KEY)`,
+	})
+
+	err := db.Migrate()
+	if err == nil || !strings.Contains(err.Error(), "failed to apply migration 0002_second") {
+		t.Fatalf("Migrate() error = %v, want migration 2 to fail", err)
+	}
+
+	if got := appliedVersions(t, db); len(got) != 1 || got[0] != 1 {
+		t.Errorf("applied versions = %v, want [1]", got)
+	}
+	for table, want := range map[string]int{"first": 1, "second": 0, "third": 0} {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != want {
+			t.Errorf("table %s exists = %t, want %t", table, count == 1, want == 1)
+		}
+	}
+
+	// Fixing the migration lets the next start continue where it stopped
+	useMigrations(t, map[string]string{
+		"0001_first.up.sql":  `CREATE TABLE first (id INTEGER PRIMARY KEY)`,
+		"0002_second.up.sql": `CREATE TABLE second (id INTEGER PRIMARY KEY)`,
+		"0003_third.up.sql":  `CREATE TABLE third (id INTEGER PRIMARY KEY)`,
+	})
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if got := appliedVersions(t, db); len(got) != 3 {
+		t.Errorf("applied versions = %v, want [1 2 3]", got)
+	}
+}
+
+func TestMigrateConcurrently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	migrations, err := loadMigrations(config.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Separate handles stand in for processes starting together
+	const processes = 4
+	dbs := make([]*DB, processes)
+	for i := range dbs {
+		dbs[i] = connectSQLite(t, path)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, processes)
+	for i, db := range dbs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = db.Migrate()
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Migrate() in process %d error = %v", i, err)
+		}
+	}
+	if got := appliedVersions(t, dbs[0]); len(got) != len(migrations) {
+		t.Errorf("applied versions = %v, want each of the %d migrations once", got, len(migrations))
+	}
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_refresh_tokens_user;
+DROP INDEX IF EXISTS idx_sessions_user;
@@ -0,0 +1,3 @@
+-- Sessions and refresh tokens are purged and revoked per user
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens (user_id);
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Dialect returns the SQL dialect of the connected database
+func (db *DB) Dialect() Dialect {
+	return db.dialect
+}
+
+// Exec rebinds and executes a query written with ? placeholders
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext is Exec run with ctx, so that the query joins ctx's trace
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.ExecContext(ctx, db.dialect.Rebind(query), args...)
+}
+
+// Query rebinds and runs a query written with ? placeholders
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext is Query run with ctx
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.QueryContext(ctx, db.dialect.Rebind(query), args...)
+}
+
+// QueryRow rebinds and runs a query written with ? placeholders that returns at most one row
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.QueryRowContext(context.Background(), query, args...)
+}
+
+// QueryRowContext is QueryRow run with ctx
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRowContext(ctx, db.dialect.Rebind(query), args...)
+}
+
+// InsertID runs an INSERT into a table with an id column and returns the new row's ID
+func (db *DB) InsertID(query string, args ...interface{}) (int64, error) {
+	return db.InsertIDContext(context.Background(), query, args...)
+}
+
+// InsertIDContext is InsertID run with ctx
+func (db *DB) InsertIDContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	return insertID(ctx, db.DB, db.dialect, query, args...)
+}
+
+// Begin starts a transaction whose queries are rebound like the DB's
+func (db *DB) Begin() (*Tx, error) {
+	return db.BeginTx(context.Background(), nil)
+}
+
+// BeginTx starts a transaction with ctx; queries run in it join ctx's trace
+// even when they are not given a context of their own
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, ctx: ctx, dialect: db.dialect}, nil
+}
+
+// Tx is a transaction that accepts queries written with ? placeholders
+type Tx struct {
+	tx      *sql.Tx
+	ctx     context.Context
+	dialect Dialect
+}
+
+// Exec rebinds and executes a query within the transaction
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.ExecContext(tx.ctx, query, args...)
+}
+
+// ExecContext is Exec run with ctx
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.tx.ExecContext(ctx, tx.dialect.Rebind(query), args...)
+}
+
+// Query rebinds and runs a query within the transaction
+func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.QueryContext(tx.ctx, query, args...)
+}
+
+// QueryContext is Query run with ctx
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.tx.QueryContext(ctx, tx.dialect.Rebind(query), args...)
+}
+
+// QueryRow rebinds and runs a single-row query within the transaction
+func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.QueryRowContext(tx.ctx, query, args...)
+}
+
+// QueryRowContext is QueryRow run with ctx
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return tx.tx.QueryRowContext(ctx, tx.dialect.Rebind(query), args...)
+}
+
+// InsertID runs an INSERT within the transaction and returns the new row's ID
+func (tx *Tx) InsertID(query string, args ...interface{}) (int64, error) {
+	return tx.InsertIDContext(tx.ctx, query, args...)
+}
+
+// InsertIDContext is InsertID run with ctx
+func (tx *Tx) InsertIDContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	return insertID(ctx, tx.tx, tx.dialect, query, args...)
+}
+
+// Commit commits the transaction
+func (tx *Tx) Commit() error {
+	return tx.tx.Commit()
+}
+
+// Rollback aborts the transaction; it is a no-op after Commit
+func (tx *Tx) Rollback() error {
+	return tx.tx.Rollback()
+}
+
+// sqlRunner is implemented by *sql.DB and *sql.Tx
+type sqlRunner interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// insertID reads the generated ID with RETURNING where supported and LastInsertId elsewhere
+func insertID(ctx context.Context, runner sqlRunner, dialect Dialect, query string, args ...interface{}) (int64, error) {
+	if dialect.SupportsReturning() {
+		var id int64
+		if err := runner.QueryRowContext(ctx, dialect.Rebind(query+" RETURNING id"), args...).Scan(&id); err != nil {
+			return 0, err
+		}
+		return id, nil
+	}
+
+	result, err := runner.ExecContext(ctx, dialect.Rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get inserted ID: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// returningSQLite reads inserted IDs with RETURNING like PostgreSQL, which SQLite supports since 3.35
+type returningSQLite struct{ sqliteDialect }
+
+func (returningSQLite) SupportsReturning() bool { return true }
+
+// openTestDB opens an SQLite database in a temporary directory with the given dialect
+func openTestDB(t *testing.T, dialect Dialect) *DB {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return &DB{DB: sqlDB, dialect: dialect}
+}
+
+func TestInsertID(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+	}{
+		{"LastInsertId", sqliteDialect{}},
+		{"RETURNING", returningSQLite{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t, tt.dialect)
+			if _, err := db.ExecContext(t.Context(), `CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT UNIQUE)`); err != nil {
+				t.Fatal(err)
+			}
+
+			for want, name := range []string{"first", "second"} {
+				id, err := db.InsertIDContext(t.Context(), `INSERT INTO items (name) VALUES (?)`, name)
+				if err != nil {
+					t.Fatalf("InsertID() error = %v", err)
+				}
+				if id != int64(want+1) {
+					t.Errorf("InsertID() = %d, want %d", id, want+1)
+				}
+			}
+
+			tx, err := db.BeginTx(t.Context(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			id, err := tx.InsertID(`INSERT INTO items (name) VALUES (?)`, "third")
+			if err != nil {
+				t.Fatalf("Tx.InsertID() error = %v", err)
+			}
+			if id != 3 {
+				t.Errorf("Tx.InsertID() = %d, want 3", id)
+			}
+			if err := tx.Commit(); err != nil {
+				t.Fatal(err)
+			}
+
+			var name string
+			if err := db.QueryRow(`SELECT name FROM items WHERE id = ?`, id).Scan(&name); err != nil || name != "third" {
+				t.Errorf("row %d = %q, %v; want the committed row", id, name, err)
+			}
+
+			if _, err := db.InsertID(`INSERT INTO items (name) VALUES (?)`, "first"); err == nil {
+				t.Error("InsertID() of a duplicate succeeded, want the constraint error")
+			}
+		})
+	}
+}
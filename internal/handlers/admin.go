@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"webui-skeleton/internal/auth"
+	"webui-skeleton/internal/config"
+	"webui-skeleton/internal/database"
+)
+
+// AdminHandler handles the admin API
+type AdminHandler struct {
+	config  *config.Config
+	db      *database.DB
+	authSvc *auth.Service
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(config *config.Config, db *database.DB, authSvc *auth.Service) *AdminHandler {
+	return &AdminHandler{
+		config:  config,
+		db:      db,
+		authSvc: authSvc,
+	}
+}
+
+// ListRoles returns every role with its permissions
+func (h *AdminHandler) ListRoles(c *gin.Context) {
+	roles, err := h.authSvc.ListRoles(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// GetUserRoles returns the roles assigned to a user
+func (h *AdminHandler) GetUserRoles(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if _, err := h.authSvc.GetUserByID(c.Request.Context(), userID); errors.Is(err, auth.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	roles, err := h.authSvc.GetUserRoles(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "roles": roles})
+}
+
+// AssignRole grants a role to a user
+func (h *AdminHandler) AssignRole(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var request struct {
+		Role string `json:"role" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	err = h.authSvc.AssignRole(c.Request.Context(), userID, request.Role)
+	switch {
+	case errors.Is(err, auth.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, auth.ErrRoleNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign role"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Role assigned successfully"})
+	}
+}
+
+// RemoveRole takes a role away from a user
+func (h *AdminHandler) RemoveRole(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	err = h.authSvc.RemoveRole(c.Request.Context(), userID, c.Param("role"))
+	switch {
+	case errors.Is(err, auth.ErrRoleNotFound), errors.Is(err, auth.ErrRoleNotAssigned):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not assigned to user"})
+	case errors.Is(err, auth.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot remove the last admin", "code": "last_admin"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove role"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Role removed successfully"})
+	}
+}
+
+// ListUsers returns a page of users, optionally filtered by a search on email and name
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	filter := auth.UserFilter{
+		Query:          c.Query("q"),
+		IncludeDeleted: c.Query("include_deleted") == "true",
+		Page:           page,
+		PerPage:        perPage,
+	}
+
+	users, total, err := h.authSvc.ListUsers(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+
+	totalPages := (total + perPage - 1) / perPage
+	meta := gin.H{
+		"page":        page,
+		"per_page":    perPage,
+		"total":       total,
+		"total_pages": totalPages,
+	}
+
+	if wantsHTML(c) {
+		c.HTML(http.StatusOK, "admin_users.html", gin.H{
+			"title":          "Users - Admin",
+			"authenticated":  true,
+			"users":          users,
+			"query":          filter.Query,
+			"includeDeleted": filter.IncludeDeleted,
+			"meta":           meta,
+			"hasPrev":        page > 1,
+			"hasNext":        page < totalPages,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "meta": meta})
+}
+
+// GetUser returns a user together with their roles, identities, sessions and API tokens
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.authSvc.GetUserByID(c.Request.Context(), userID)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	roles, err := h.authSvc.GetUserRoles(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user roles"})
+		return
+	}
+	identities, err := h.authSvc.ListIdentities(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list identities"})
+		return
+	}
+	sessions, err := h.authSvc.ListUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+		return
+	}
+	tokens, err := h.authSvc.ListAPITokens(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API tokens"})
+		return
+	}
+
+	data := gin.H{
+		"user":       user,
+		"roles":      roles,
+		"identities": identities,
+		"sessions":   sessions,
+		"api_tokens": tokens,
+	}
+
+	if wantsHTML(c) {
+		allRoles, err := h.authSvc.ListRoles(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list roles"})
+			return
+		}
+		data["title"] = user.Name + " - Admin"
+		data["authenticated"] = true
+		data["allRoles"] = allRoles
+		c.HTML(http.StatusOK, "admin_user.html", data)
+		return
+	}
+
+	c.JSON(http.StatusOK, data)
+}
+
+// DisableUser blocks a user and ends their sessions
+func (h *AdminHandler) DisableUser(c *gin.Context) {
+	h.updateUser(c, h.authSvc.DisableUser, "User disabled successfully")
+}
+
+// EnableUser unblocks a disabled user
+func (h *AdminHandler) EnableUser(c *gin.Context) {
+	h.updateUser(c, h.authSvc.EnableUser, "User enabled successfully")
+}
+
+// RestoreUser brings back a soft-deleted user
+func (h *AdminHandler) RestoreUser(c *gin.Context) {
+	h.updateUser(c, h.authSvc.RestoreUser, "User restored successfully")
+}
+
+// DeleteUser soft-deletes a user, or removes them permanently with ?hard=true
+func (h *AdminHandler) DeleteUser(c *gin.Context) {
+	if c.Query("hard") == "true" {
+		h.updateUser(c, h.authSvc.DeleteUser, "User deleted permanently")
+		return
+	}
+	h.updateUser(c, h.authSvc.SoftDeleteUser, "User deleted successfully")
+}
+
+// LogoutUser revokes every session of a user
+func (h *AdminHandler) LogoutUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	revoked, err := h.authSvc.RevokeUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User logged out", "revoked_sessions": revoked})
+}
+
+// updateUser applies a status change to the user in the :id parameter.
+// Admins can't apply these changes to their own account.
+func (h *AdminHandler) updateUser(c *gin.Context, update func(ctx context.Context, userID int) error, message string) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if currentID, _, _, _ := auth.GetUserFromContext(c); currentID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot change the status of your own account"})
+		return
+	}
+
+	err = update(c.Request.Context(), userID)
+	switch {
+	case errors.Is(err, auth.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, auth.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot remove the last admin", "code": "last_admin"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": message})
+	}
+}
+
+// wantsHTML reports whether the client prefers an HTML page over JSON
+func wantsHTML(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+}
@@ -7,6 +7,7 @@ import (
 	"webui-skeleton/internal/auth"
 	"webui-skeleton/internal/config"
 	"webui-skeleton/internal/database"
+	"webui-skeleton/internal/version"
 )
 
 // APIHandler handles API endpoints
@@ -29,7 +30,7 @@ func NewAPIHandler(config *config.Config, db *database.DB, authSvc *auth.Service
 func (h *APIHandler) Status(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "ok",
-		"version": "1.0.0",
+		"version": version.Get().Version,
 		"api":     "v1",
 		"app":     "webui-skeleton",
 	})
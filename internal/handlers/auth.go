@@ -1,12 +1,22 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"webui-skeleton/internal/auth"
 	"webui-skeleton/internal/config"
 	"webui-skeleton/internal/database"
+	"webui-skeleton/internal/logger"
+	"webui-skeleton/internal/metrics"
+	"webui-skeleton/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // AuthHandler handles authentication-related requests
@@ -34,56 +44,183 @@ func (h *AuthHandler) LoginPage(c *gin.Context) {
 	}
 
 	c.HTML(http.StatusOK, "login.html", gin.H{
-		"title": "Login - WebUI Skeleton",
+		"title":     "Login - WebUI Skeleton",
+		"returnTo":  auth.SanitizeReturnTo(c.Query("return_to")),
+		"providers": h.authSvc.Providers(),
 	})
 }
 
-// GoogleLogin initiates Google OAuth login
-func (h *AuthHandler) GoogleLogin(c *gin.Context) {
-	url := h.authSvc.GetGoogleAuthURL()
+// ProviderLogin initiates login with the identity provider named in the route
+func (h *AuthHandler) ProviderLogin(c *gin.Context) {
+	h.startOAuth(c, 0)
+}
+
+// ProviderLink initiates linking the identity provider named in the route to the current user
+func (h *AuthHandler) ProviderLink(c *gin.Context) {
+	userID, _, _, exists := auth.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	h.startOAuth(c, userID)
+}
+
+// startOAuth redirects the browser to the provider, binding a new state to it
+func (h *AuthHandler) startOAuth(c *gin.Context, linkUserID int) {
+	provider, ok := h.authSvc.Provider(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+		return
+	}
+
+	state, cookie, err := h.authSvc.NewOAuthState(provider.Name(), c.Query("return_to"), linkUserID)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error().Err(err).Msg("Failed to create OAuth state")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	url, err := provider.AuthCodeURL(c.Request.Context(), state)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error().Err(err).Str("provider", provider.Name()).Msg("Identity provider unavailable")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	// Bind the state to this browser; Lax so it survives the top-level redirect back from the provider
+	c.SetSameSite(http.SameSiteLaxMode)
+	auth.SetCookie(c, auth.StateCookieName, cookie, int(auth.StateTTL.Seconds()), "/auth")
+
 	c.Redirect(http.StatusTemporaryRedirect, url)
 }
 
-// GoogleCallback handles the Google OAuth callback
-func (h *AuthHandler) GoogleCallback(c *gin.Context) {
+// ProviderCallback handles the OAuth callback from the identity provider named in the route
+func (h *AuthHandler) ProviderCallback(c *gin.Context) {
+	provider, ok := h.authSvc.Provider(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+		return
+	}
+
+	// Verify the state before touching the authorization code
+	stateCookie, _ := c.Cookie(auth.StateCookieName)
+	auth.SetCookie(c, auth.StateCookieName, "", -1, "/auth")
+
+	state, err := h.authSvc.VerifyOAuthState(stateCookie, provider.Name(), c.Query("state"))
+	if err != nil {
+		metrics.RecordLogin(provider.Name(), "invalid_state")
+		respondStateError(c, err)
+		return
+	}
+
 	code := c.Query("code")
 	if code == "" {
+		metrics.RecordLogin(provider.Name(), "no_code")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code not provided"})
 		return
 	}
 
-	// Exchange code for user info
-	userInfo, err := h.authSvc.ExchangeCodeForToken(c.Request.Context(), code)
+	// Exchange code for user info; the span groups the provider's token and profile requests
+	ctx, span := tracing.Tracer().Start(c.Request.Context(), "oauth.exchange",
+		trace.WithAttributes(attribute.String("oauth.provider", provider.Name())))
+	userInfo, err := provider.Exchange(ctx, code, state)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "exchange failed")
+	}
+	span.End()
 	if err != nil {
+		logger.FromContext(c.Request.Context()).Error().Err(err).Str("provider", provider.Name()).Msg("Failed to exchange authorization code")
+		metrics.RecordLogin(provider.Name(), "exchange_failed")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user info"})
 		return
 	}
 
+	if state.LinkUserID != 0 {
+		h.completeLink(c, state, userInfo)
+		return
+	}
+
 	// Create or update user
-	user, err := h.authSvc.CreateOrUpdateUser(userInfo)
-	if err != nil {
+	user, err := h.authSvc.CreateOrUpdateUser(c.Request.Context(), userInfo)
+	if errors.Is(err, auth.ErrAccountExists) {
+		metrics.RecordLogin(provider.Name(), "account_exists")
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "An account with this email already exists. Sign in with your existing login and link this provider from your profile.",
+			"code":  "account_exists",
+		})
+		return
+	} else if err != nil {
+		logger.FromContext(c.Request.Context()).Error().Err(err).Msg("Failed to create or update user")
+		metrics.RecordLogin(provider.Name(), "error")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create/update user"})
 		return
 	}
 
-	// Generate JWT token
-	token, err := h.authSvc.GenerateJWT(user)
+	// Start a session with an access and refresh token
+	tokens, err := h.authSvc.IssueTokens(c.Request.Context(), user)
 	if err != nil {
+		logger.FromContext(c.Request.Context()).Error().Err(err).Int("user_id", user.ID).Msg("Failed to issue tokens")
+		metrics.RecordLogin(provider.Name(), "error")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	// Set auth cookie for web UI
-	c.SetCookie("auth_token", token, int(h.config.Auth.JWTExpiresIn.Seconds()), "/", "", false, true)
+	// Set auth cookies for web UI
+	auth.SetAuthCookies(c, tokens)
+	metrics.RecordLogin(provider.Name(), "success")
 
-	// Redirect to dashboard
-	c.Redirect(http.StatusFound, "/dashboard")
+	// Redirect back to where the login started
+	c.Redirect(http.StatusFound, state.ReturnTo)
 }
 
-// Logout handles user logout
+// completeLink attaches the identity returned by the provider to the user who started the link flow
+func (h *AuthHandler) completeLink(c *gin.Context, state *auth.OAuthState, identity *auth.ExternalIdentity) {
+	// The link must finish in the same session that started it
+	userID, _, _, exists := auth.GetUserFromContext(c)
+	if !exists || userID != state.LinkUserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Link must be completed by the user who started it", "code": "link_user_mismatch"})
+		return
+	}
+
+	err := h.authSvc.LinkIdentity(c.Request.Context(), userID, identity)
+	if errors.Is(err, auth.ErrIdentityLinked) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This login is already linked to another account", "code": "identity_linked"})
+		return
+	} else if err != nil {
+		logger.FromContext(c.Request.Context()).Error().Err(err).Msg("Failed to link identity")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link identity"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, state.ReturnTo)
+}
+
+// respondStateError maps OAuth state verification errors to client responses
+func respondStateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrStateMissing):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OAuth state missing, please start the login again", "code": "state_missing"})
+	case errors.Is(err, auth.ErrStateExpired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OAuth state expired, please start the login again", "code": "state_expired"})
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "OAuth state mismatch", "code": "state_mismatch"})
+	}
+}
+
+// Logout clears the auth cookies; POST requests also revoke the session
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// Clear the auth cookie
-	c.SetCookie("auth_token", "", -1, "/", "", false, true)
+	// Revoke the server-side session so the token stops working even if it was copied
+	if sessionID, ok := auth.GetSessionIDFromContext(c); ok && c.Request.Method == http.MethodPost {
+		if err := h.authSvc.RevokeSession(c.Request.Context(), sessionID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+			return
+		}
+	}
+
+	// Clear the auth cookies
+	auth.ClearAuthCookies(c)
 
 	if c.GetHeader("Content-Type") == "application/json" {
 		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
@@ -92,6 +229,83 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	}
 }
 
+// RefreshToken rotates a refresh token, taken from the JSON body or the web UI cookie,
+// and returns a new access and refresh token pair
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	fromCookie := false
+	if c.ContentType() == "application/json" {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+	}
+	if request.RefreshToken == "" {
+		request.RefreshToken, _ = c.Cookie(auth.RefreshCookieName)
+		fromCookie = true
+	}
+	if request.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token not provided"})
+		return
+	}
+
+	tokens, err := h.authSvc.RefreshTokens(c.Request.Context(), request.RefreshToken)
+	switch {
+	case errors.Is(err, auth.ErrRefreshTokenReused):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, session revoked", "code": "refresh_token_reused"})
+		return
+	case errors.Is(err, auth.ErrRefreshTokenRaced):
+		c.JSON(http.StatusConflict, gin.H{"error": "Refresh token was already rotated", "code": "refresh_token_rotated"})
+		return
+	case errors.Is(err, auth.ErrInvalidRefreshToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token", "code": "invalid_refresh_token"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	if fromCookie {
+		auth.SetAuthCookies(c, tokens)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    int(time.Until(tokens.AccessExpiresAt).Seconds()),
+	})
+}
+
+// LogoutAll revokes every session of the current user
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, _, _, exists := auth.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	revoked, err := h.authSvc.RevokeUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	auth.ClearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Logged out of all sessions",
+		"revoked_sessions": revoked,
+	})
+}
+
+// JWKS publishes the public keys that verify the tokens we issue
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.authSvc.JWKS())
+}
+
 // GetCurrentUser returns the current authenticated user info
 func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 	userID, email, name, exists := auth.GetUserFromContext(c)
@@ -115,16 +329,66 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	user, err := h.authSvc.GetUserByID(userID)
+	user, err := h.authSvc.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
+	roles, _ := auth.GetRolesFromContext(c)
+
 	c.JSON(http.StatusOK, gin.H{
 		"id":      user.ID,
 		"email":   user.Email,
 		"name":    user.Name,
 		"picture": user.Picture,
+		"roles":   roles,
+	})
+}
+
+// ListIdentities returns the external identities linked to the current user
+func (h *AuthHandler) ListIdentities(c *gin.Context) {
+	userID, _, _, exists := auth.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	identities, err := h.authSvc.ListIdentities(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list identities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"identities": identities,
+		"providers":  h.authSvc.Providers(),
 	})
 }
+
+// UnlinkIdentity removes one of the current user's external identities
+func (h *AuthHandler) UnlinkIdentity(c *gin.Context) {
+	userID, _, _, exists := auth.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	identityID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid identity ID"})
+		return
+	}
+
+	err = h.authSvc.UnlinkIdentity(c.Request.Context(), userID, identityID)
+	switch {
+	case errors.Is(err, auth.ErrIdentityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity not found"})
+	case errors.Is(err, auth.ErrLastIdentity):
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot unlink your only login method", "code": "last_identity"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked successfully"})
+	}
+}
@@ -6,27 +6,63 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"webui-skeleton/internal/config"
+	"webui-skeleton/internal/health"
+	"webui-skeleton/internal/version"
 )
 
 // HealthHandler handles health check endpoints
 type HealthHandler struct {
 	config *config.Config
+	checks *health.Registry
 }
 
-// NewHealthHandler creates a new health handler
-func NewHealthHandler(config *config.Config) *HealthHandler {
+// NewHealthHandler creates a new health handler for the checks in registry
+func NewHealthHandler(config *config.Config, checks *health.Registry) *HealthHandler {
 	return &HealthHandler{
 		config: config,
+		checks: checks,
 	}
 }
 
-// HealthCheck returns the health status of the application
-func (h *HealthHandler) HealthCheck(c *gin.Context) {
+// Livez reports that the process is running. It checks no dependencies, so that
+// an unreachable database doesn't get the process restarted.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	uptime := h.checks.Uptime()
 	c.JSON(http.StatusOK, gin.H{
-		"status":    "healthy",
+		"status":         health.StatusOK,
+		"uptime":         uptime.Truncate(time.Second).String(),
+		"uptime_seconds": int64(uptime.Seconds()),
+	})
+}
+
+// Readyz runs the dependency checks and responds 503 when the application
+// should not receive traffic, including while it shuts down
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	report := h.checks.Ready(c.Request.Context())
+	c.JSON(readyStatus(report), gin.H{
+		"status": report.Status,
+		"checks": report.Checks,
+		"uptime": h.checks.Uptime().Truncate(time.Second).String(),
+	})
+}
+
+// HealthCheck returns the readiness of the application along with its version
+func (h *HealthHandler) HealthCheck(c *gin.Context) {
+	report := h.checks.Ready(c.Request.Context())
+	c.JSON(readyStatus(report), gin.H{
+		"status":    report.Status,
+		"checks":    report.Checks,
 		"app":       "webui-skeleton",
-		"version":   "1.0.0",
+		"version":   version.Get().Version,
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"uptime":    "running",
+		"uptime":    h.checks.Uptime().Truncate(time.Second).String(),
 	})
 }
+
+// readyStatus is the HTTP status for a readiness report
+func readyStatus(report health.Report) int {
+	if report.Ready() {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
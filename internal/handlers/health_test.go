@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"webui-skeleton/internal/config"
+	"webui-skeleton/internal/health"
+)
+
+// probe requests path from an engine serving the health endpoints of checks
+func probe(t *testing.T, checks *health.Registry, path string) (int, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	h := NewHealthHandler(&config.Config{}, checks)
+	engine := gin.New()
+	engine.GET("/livez", h.Livez)
+	engine.GET("/readyz", h.Readyz)
+	engine.GET("/health", h.HealthCheck)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s returned invalid JSON: %v", path, err)
+	}
+	return w.Code, body.Status
+}
+
+func TestHealthProbes(t *testing.T) {
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+	up := func(ctx context.Context) error { return nil }
+
+	tests := []struct {
+		name       string
+		checks     []health.Check
+		draining   bool
+		wantCode   int
+		wantStatus string
+	}{
+		{
+			name:       "healthy",
+			checks:     []health.Check{{Name: "database", Run: up}},
+			wantCode:   http.StatusOK,
+			wantStatus: health.StatusOK,
+		},
+		{
+			name:       "required check failing",
+			checks:     []health.Check{{Name: "database", Run: down}},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: health.StatusFailing,
+		},
+		{
+			name:       "optional check failing",
+			checks:     []health.Check{{Name: "database", Run: up}, {Name: "jwks", Run: down, Optional: true}},
+			wantCode:   http.StatusOK,
+			wantStatus: health.StatusDegraded,
+		},
+		{
+			name:       "draining",
+			checks:     []health.Check{{Name: "database", Run: up}},
+			draining:   true,
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: health.StatusShuttingDown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := health.NewRegistry()
+			for _, check := range tt.checks {
+				checks.Register(check)
+			}
+			if tt.draining {
+				checks.SetDraining()
+			}
+
+			for _, path := range []string{"/readyz", "/health"} {
+				if code, status := probe(t, checks, path); code != tt.wantCode || status != tt.wantStatus {
+					t.Errorf("%s = %d %s, want %d %s", path, code, status, tt.wantCode, tt.wantStatus)
+				}
+			}
+			// Liveness doesn't depend on the checks
+			if code, status := probe(t, checks, "/livez"); code != http.StatusOK || status != health.StatusOK {
+				t.Errorf("/livez = %d %s, want %d %s", code, status, http.StatusOK, health.StatusOK)
+			}
+		})
+	}
+}
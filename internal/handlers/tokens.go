@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"webui-skeleton/internal/auth"
+)
+
+// ListAPITokens returns the current user's API tokens
+func (h *AuthHandler) ListAPITokens(c *gin.Context) {
+	userID, _, _, exists := auth.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tokens, err := h.authSvc.ListAPITokens(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// CreateAPIToken creates an API token for the current user and returns its secret once
+func (h *AuthHandler) CreateAPIToken(c *gin.Context) {
+	userID, _, _, exists := auth.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var request struct {
+		Name      string     `json:"name" binding:"required,max=255"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	if len(request.Scopes) == 0 {
+		request.Scopes = []string{auth.ScopeRead}
+	}
+
+	if request.ExpiresAt != nil && request.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expiry must be in the future"})
+		return
+	}
+
+	// A token can't be used to mint a token with more access than itself
+	if callerScopes, ok := auth.GetTokenScopesFromContext(c); ok {
+		for _, scope := range request.Scopes {
+			if !slices.Contains(callerScopes, scope) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Cannot grant scopes beyond those of the current token", "code": "insufficient_scope"})
+				return
+			}
+		}
+	}
+
+	token, secret, err := h.authSvc.CreateAPIToken(c.Request.Context(), userID, request.Name, request.Scopes, request.ExpiresAt)
+	switch {
+	case errors.Is(err, auth.ErrInvalidScope):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "valid_scopes": auth.ValidScopes})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API token"})
+	default:
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "API token created; copy it now, it won't be shown again",
+			"token":   token,
+			"secret":  secret,
+		})
+	}
+}
+
+// RevokeAPIToken deletes one of the current user's API tokens
+func (h *AuthHandler) RevokeAPIToken(c *gin.Context) {
+	userID, _, _, exists := auth.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tokenID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	err = h.authSvc.RevokeAPIToken(c.Request.Context(), userID, tokenID)
+	switch {
+	case errors.Is(err, auth.ErrAPITokenNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "API token not found"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API token"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "API token revoked successfully"})
+	}
+}
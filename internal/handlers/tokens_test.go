@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"webui-skeleton/internal/auth"
+	"webui-skeleton/internal/config"
+	"webui-skeleton/internal/database"
+)
+
+// newTestAuthService returns an auth service backed by a migrated SQLite database
+func newTestAuthService(t *testing.T) *auth.Service {
+	t.Helper()
+	db := database.New(&config.DatabaseConfig{Type: config.SQLite, Database: filepath.Join(t.TempDir(), "handlers.db")})
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := auth.NewService(db, &config.AuthConfig{
+		JWTSecret:             "test-jwt-secret-that-is-long-enough",
+		JWTAlgorithm:          "HS256",
+		JWTExpiresIn:          15 * time.Minute,
+		JWTIssuer:             "test",
+		RefreshTokenExpiresIn: 24 * time.Hour,
+		SessionSecret:         "test-session-secret",
+		AccountLinking:        config.AccountLinkingNever,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCreateAPITokenScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	s := newTestAuthService(t)
+	user, err := s.CreateUser(ctx, "alice@example.com", "Alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := s.IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, writeOnly, err := s.CreateAPIToken(ctx, user.ID, "writer", []string{auth.ScopeWrite}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, readWrite, err := s.CreateAPIToken(ctx, user.ID, "full", []string{auth.ScopeRead, auth.ScopeWrite}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewAuthHandler(&config.Config{}, nil, s)
+	engine := gin.New()
+	engine.Use(s.Middleware())
+	engine.POST("/api/tokens", h.CreateAPIToken)
+
+	tests := []struct {
+		name       string
+		bearer     string
+		body       string
+		wantStatus int
+	}{
+		{"session grants any scope", session.AccessToken, `{"name": "new", "scopes": ["read", "write"]}`, http.StatusCreated},
+		{"token grants its own scopes", readWrite, `{"name": "new", "scopes": ["write"]}`, http.StatusCreated},
+		{"token can't grant more", writeOnly, `{"name": "new", "scopes": ["read", "write"]}`, http.StatusForbidden},
+		{"token can't grant the default read scope", writeOnly, `{"name": "new"}`, http.StatusForbidden},
+		{"unknown scope", session.AccessToken, `{"name": "new", "scopes": ["admin"]}`, http.StatusBadRequest},
+		{"expiry in the past", session.AccessToken, `{"name": "new", "expires_at": "2000-01-01T00:00:00Z"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/tokens", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout bounds checks that don't set their own timeout
+const DefaultTimeout = 2 * time.Second
+
+// Check statuses, and the overall statuses of a report
+const (
+	StatusOK           = "ok"
+	StatusFailing      = "failing"
+	StatusDegraded     = "degraded"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Check is a named dependency check run by the readiness probe
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+
+	// Timeout bounds Run; zero uses DefaultTimeout
+	Timeout time.Duration
+
+	// Optional checks are reported, but failing ones only degrade readiness
+	Optional bool
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+	Optional   bool    `json:"optional,omitempty"`
+}
+
+// Report is the outcome of all checks
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Ready reports whether the application should receive traffic
+func (r Report) Ready() bool {
+	return r.Status == StatusOK || r.Status == StatusDegraded
+}
+
+// Registry holds the readiness checks and the process start time
+type Registry struct {
+	started  time.Time
+	draining atomic.Bool
+
+	mu     sync.RWMutex
+	checks []Check
+}
+
+// NewRegistry creates an empty registry; uptime counts from now
+func NewRegistry() *Registry {
+	return &Registry{started: time.Now()}
+}
+
+// Register adds a check to the readiness probe
+func (r *Registry) Register(check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, check)
+}
+
+// Uptime returns how long ago the registry was created
+func (r *Registry) Uptime() time.Duration {
+	return time.Since(r.started)
+}
+
+// SetDraining makes the readiness probe fail from now on, so that load
+// balancers stop sending requests before the server shuts down
+func (r *Registry) SetDraining() {
+	r.draining.Store(true)
+}
+
+// Ready runs every check concurrently, each with its own timeout
+func (r *Registry) Ready(ctx context.Context) Report {
+	if r.draining.Load() {
+		return Report{Status: StatusShuttingDown, Checks: []Result{}}
+	}
+
+	r.mu.RLock()
+	checks := append([]Check(nil), r.checks...)
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, result := range results {
+		if result.Status == StatusOK {
+			continue
+		}
+		if !result.Optional {
+			report.Status = StatusFailing
+			break
+		}
+		report.Status = StatusDegraded
+	}
+	return report
+}
+
+// run executes one check within its timeout. A check that ignores its context
+// is reported as failed at the timeout rather than holding up the probe.
+func run(ctx context.Context, check Check) Result {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check.Run(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	result := Result{
+		Name:       check.Name,
+		Status:     StatusOK,
+		DurationMs: float64(time.Since(start)) / float64(time.Millisecond),
+		Optional:   check.Optional,
+	}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
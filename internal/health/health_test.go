@@ -0,0 +1,126 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func passing(ctx context.Context) error { return nil }
+
+func failing(ctx context.Context) error { return errors.New("connection refused") }
+
+func TestRegistryReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     []Check
+		wantStatus string
+		wantReady  bool
+	}{
+		{
+			name:       "no checks",
+			wantStatus: StatusOK,
+			wantReady:  true,
+		},
+		{
+			name:       "all passing",
+			checks:     []Check{{Name: "database", Run: passing}, {Name: "jwks", Run: passing, Optional: true}},
+			wantStatus: StatusOK,
+			wantReady:  true,
+		},
+		{
+			name:       "required failing",
+			checks:     []Check{{Name: "database", Run: failing}, {Name: "jwks", Run: passing, Optional: true}},
+			wantStatus: StatusFailing,
+			wantReady:  false,
+		},
+		{
+			name:       "optional failing",
+			checks:     []Check{{Name: "database", Run: passing}, {Name: "jwks", Run: failing, Optional: true}},
+			wantStatus: StatusDegraded,
+			wantReady:  true,
+		},
+		{
+			name:       "required and optional failing",
+			checks:     []Check{{Name: "jwks", Run: failing, Optional: true}, {Name: "database", Run: failing}},
+			wantStatus: StatusFailing,
+			wantReady:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			for _, check := range tt.checks {
+				r.Register(check)
+			}
+			report := r.Ready(context.Background())
+			if report.Status != tt.wantStatus || report.Ready() != tt.wantReady {
+				t.Errorf("Ready() = %s (ready %t), want %s (ready %t)", report.Status, report.Ready(), tt.wantStatus, tt.wantReady)
+			}
+			if len(report.Checks) != len(tt.checks) {
+				t.Fatalf("Ready() reported %d checks, want %d", len(report.Checks), len(tt.checks))
+			}
+			for i, result := range report.Checks {
+				if result.Name != tt.checks[i].Name || result.Optional != tt.checks[i].Optional {
+					t.Errorf("check %d = %+v, want %s in registration order", i, result, tt.checks[i].Name)
+				}
+				if (result.Status == StatusFailing) != (result.Error != "") {
+					t.Errorf("check %s = %+v, want an error exactly when failing", result.Name, result)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistryReadyTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	r := NewRegistry()
+	r.Register(Check{
+		Name:    "respects context",
+		Timeout: 20 * time.Millisecond,
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	r.Register(Check{
+		Name:    "ignores context",
+		Timeout: 20 * time.Millisecond,
+		Run: func(ctx context.Context) error {
+			<-release
+			return nil
+		},
+	})
+
+	start := time.Now()
+	report := r.Ready(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Ready() took %s, want it bounded by the check timeouts", elapsed)
+	}
+	if report.Status != StatusFailing {
+		t.Errorf("Ready() = %s, want %s", report.Status, StatusFailing)
+	}
+	for _, result := range report.Checks {
+		if result.Status != StatusFailing || result.Error == "" {
+			t.Errorf("check %q = %+v, want failing after its timeout", result.Name, result)
+		}
+	}
+}
+
+func TestRegistryDraining(t *testing.T) {
+	r := NewRegistry()
+	r.Register(Check{Name: "database", Run: passing})
+	if report := r.Ready(context.Background()); !report.Ready() {
+		t.Fatalf("Ready() = %s before draining, want ready", report.Status)
+	}
+
+	r.SetDraining()
+	report := r.Ready(context.Background())
+	if report.Status != StatusShuttingDown || report.Ready() {
+		t.Errorf("Ready() = %s while draining, want %s and not ready", report.Status, StatusShuttingDown)
+	}
+}
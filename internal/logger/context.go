@@ -0,0 +1,24 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+type contextKey struct{}
+
+// WithContext returns a copy of ctx that carries l, e.g. a request-scoped logger
+func WithContext(ctx context.Context, l *zerolog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, which for HTTP requests includes the
+// request ID and, once authenticated, the user ID. Without one it returns a copy of Log.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*zerolog.Logger); ok {
+		return l
+	}
+	l := Log
+	return &l
+}
@@ -55,3 +55,10 @@ func parseLogLevel(logLevel string) zerolog.Level {
 		return zerolog.InfoLevel
 	}
 }
+
+// SetLevel changes the log level of the running application
+func SetLevel(logLevel string) {
+	level := parseLogLevel(logLevel)
+	zerolog.SetGlobalLevel(level)
+	Log.Info().Str("level", level.String()).Msg("Log level changed")
+}
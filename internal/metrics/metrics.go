@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds the application metrics along with the Go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route pattern and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Logins through an identity provider by provider and result.",
+	}, []string{"provider", "result"})
+
+	tokenValidationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_token_validation_failures_total",
+		Help: "Rejected access and API tokens by reason.",
+	}, []string{"reason"})
+
+	tokenRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_token_refreshes_total",
+		Help: "Refresh token rotations by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		logins,
+		tokenValidationFailures,
+		tokenRefreshes,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// RegisterDatabase exposes the connection pool statistics of db, labeled with name
+func RegisterDatabase(db *sql.DB, name string) error {
+	if err := Registry.Register(collectors.NewDBStatsCollector(db, name)); err != nil {
+		return fmt.Errorf("failed to register database metrics: %w", err)
+	}
+	return nil
+}
+
+// ObserveHTTPRequest records a completed request. route is the route pattern, not
+// the path, so that IDs in paths don't create a time series each.
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpRequestDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// RecordLogin counts a login attempt that reached the provider callback
+func RecordLogin(provider, result string) {
+	logins.WithLabelValues(provider, result).Inc()
+}
+
+// RecordTokenValidationFailure counts a rejected token
+func RecordTokenValidationFailure(reason string) {
+	tokenValidationFailures.WithLabelValues(reason).Inc()
+}
+
+// RecordTokenRefresh counts a refresh token rotation
+func RecordTokenRefresh(result string) {
+	tokenRefreshes.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"webui-skeleton/internal/auth"
+	"webui-skeleton/internal/config"
+	"webui-skeleton/internal/logger"
+	"webui-skeleton/internal/metrics"
+	"webui-skeleton/internal/tracing"
+)
+
+// RequestIDHeader carries the request ID, taken from the client or a proxy when valid
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// requestLogger assigns every request an ID, attaches a logger with it to the
+// request context and writes one access log line when the request completes
+func requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header(RequestIDHeader, requestID)
+		c.Set("request_id", requestID)
+
+		// The auth middleware adds the user ID to this logger
+		logContext := logger.Log.With().Str("request_id", requestID)
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.IsValid() {
+			logContext = logContext.Str("trace_id", span.TraceID().String())
+		}
+		requestLog := logContext.Logger()
+		c.Request = c.Request.WithContext(logger.WithContext(c.Request.Context(), &requestLog))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		var event *zerolog.Event
+		switch {
+		case status >= 500:
+			event = requestLog.Error()
+		case status >= 400:
+			event = requestLog.Warn()
+		default:
+			event = requestLog.Info()
+		}
+
+		route := routePattern(c)
+
+		// Size is -1 until the body is written
+		bytes := c.Writer.Size()
+		if bytes < 0 {
+			bytes = 0
+		}
+
+		event.
+			Str("method", c.Request.Method).
+			Str("path", c.Request.URL.Path).
+			Str("route", route).
+			Int("status", status).
+			Int("bytes", bytes).
+			Float64("latency_ms", float64(time.Since(start))/float64(time.Millisecond)).
+			Str("client_ip", c.ClientIP()).
+			Str("user_agent", c.Request.UserAgent())
+		if len(c.Errors) > 0 {
+			event.Str("errors", c.Errors.String())
+		}
+		event.Msg("request")
+	}
+}
+
+// requestTracing continues the trace of an incoming traceparent header, or starts
+// a new one, with a server span per request named after the route pattern
+func requestTracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		// Unmatched requests are named by the method alone, as the conventions recommend
+		name := c.Request.Method
+		attributes := []attribute.KeyValue{
+			semconv.HTTPRequestMethodKey.String(c.Request.Method),
+			semconv.URLPath(c.Request.URL.Path),
+			semconv.ClientAddress(c.ClientIP()),
+			semconv.UserAgentOriginal(c.Request.UserAgent()),
+		}
+		if route := c.FullPath(); route != "" {
+			name += " " + route
+			attributes = append(attributes, semconv.HTTPRoute(route))
+		}
+
+		ctx, span := tracing.Tracer().Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attributes...))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if len(c.Errors) > 0 {
+			span.SetAttributes(attribute.String("gin.errors", c.Errors.String()))
+		}
+	}
+}
+
+// transportSecurity marks requests that reached a trusted proxy over HTTPS, so that
+// auth.IsHTTPS and the Secure cookie flag cover them, and sends HSTS on HTTPS responses
+func transportSecurity(trustedProxies []netip.Prefix, tlsConfig *config.TLSConfig) gin.HandlerFunc {
+	hsts := ""
+	if tlsConfig.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int64(tlsConfig.HSTSMaxAge.Seconds()))
+		if tlsConfig.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.TLS == nil && strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") && isTrustedProxy(c.RemoteIP(), trustedProxies) {
+			c.Set("https", true)
+		}
+		// Browsers ignore HSTS received over plain HTTP
+		if hsts != "" && auth.IsHTTPS(c) {
+			c.Header("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
+
+// parseTrustedProxies parses the IP addresses and CIDR ranges of server.trusted_proxies
+func parseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		if addr, err := netip.ParseAddr(proxy); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse trusted proxy: %w", err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// isTrustedProxy reports whether the peer address ip is one of the trusted proxies
+func isTrustedProxy(ip string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestMetrics records the count and latency of every request by route pattern
+func requestMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		metrics.ObserveHTTPRequest(c.Request.Method, routePattern(c), c.Writer.Status(), time.Since(start))
+	}
+}
+
+// routePattern returns the pattern of the matched route, e.g. /admin/users/:id;
+// unmatched requests share one value so that scanners can't create unbounded labels
+func routePattern(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return "unmatched"
+}
+
+// GetRequestID returns the ID of the current request
+func GetRequestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
+
+// validRequestID accepts short IDs of printable characters, so that client
+// input can't forge log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"webui-skeleton/internal/config"
+	"webui-skeleton/internal/database"
+	"webui-skeleton/internal/metrics"
+	"webui-skeleton/internal/tracing"
+)
+
+// useInMemoryTracing installs a tracer provider that records spans in memory for the test
+func useInMemoryTracing(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider(&config.TracingConfig{ServiceName: "test", SampleRatio: 1}, sdktrace.WithSyncer(exporter))
+
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		provider.Shutdown(t.Context())
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return exporter
+}
+
+func TestRequestTracing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exporter := useInMemoryTracing(t)
+
+	// Connect after installing the provider, which otelsql picks up when opening
+	db := database.New(&config.DatabaseConfig{Type: config.SQLite, Database: filepath.Join(t.TempDir(), "test.db")})
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	engine := gin.New()
+	engine.Use(requestTracing())
+	engine.GET("/items/:id", func(c *gin.Context) {
+		var one int
+		if err := db.QueryRowContext(c.Request.Context(), `SELECT 1`).Scan(&one); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	const (
+		callerTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		callerSpanID  = "00f067aa0ba902b7"
+	)
+
+	tests := []struct {
+		name        string
+		traceparent string
+	}{
+		{name: "new trace"},
+		{name: "caller's trace", traceparent: "00-" + callerTraceID + "-" + callerSpanID + "-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+
+			req := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusNoContent)
+			}
+
+			var server *tracetest.SpanStub
+			var queries []tracetest.SpanStub
+			for _, span := range exporter.GetSpans() {
+				switch {
+				case span.SpanKind == trace.SpanKindServer:
+					server = &span
+				case strings.HasPrefix(span.Name, "sql.") && strings.HasSuffix(span.Name, "query"):
+					queries = append(queries, span)
+				}
+			}
+			if server == nil {
+				t.Fatalf("no server span in %d spans", len(exporter.GetSpans()))
+			}
+			if server.Name != "GET /items/:id" {
+				t.Errorf("server span name = %q, want %q", server.Name, "GET /items/:id")
+			}
+
+			if tt.traceparent == "" {
+				if server.Parent.IsValid() {
+					t.Errorf("server span has parent %s, want a root span", server.Parent.SpanID())
+				}
+			} else {
+				if got := server.SpanContext.TraceID().String(); got != callerTraceID {
+					t.Errorf("server span trace ID = %s, want the caller's %s", got, callerTraceID)
+				}
+				if got := server.Parent.SpanID().String(); got != callerSpanID || !server.Parent.IsRemote() {
+					t.Errorf("server span parent = %s (remote %t), want the caller's span %s", got, server.Parent.IsRemote(), callerSpanID)
+				}
+			}
+
+			if len(queries) == 0 {
+				t.Fatal("no query span recorded")
+			}
+			for _, query := range queries {
+				if query.SpanContext.TraceID() != server.SpanContext.TraceID() || query.Parent.SpanID() != server.SpanContext.SpanID() {
+					t.Errorf("%s span parent = %s/%s, want the server span %s/%s", query.Name,
+						query.Parent.TraceID(), query.Parent.SpanID(), server.SpanContext.TraceID(), server.SpanContext.SpanID())
+				}
+			}
+		})
+	}
+}
+
+func TestRequestMetricsCountsPanics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(requestMetrics())
+	engine.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, _ any) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	engine.GET("/test/panic", func(*gin.Context) { panic("boom") })
+
+	before := requestCount(t, "/test/panic", "500")
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test/panic", nil))
+	if got := requestCount(t, "/test/panic", "500"); got != before+1 {
+		t.Errorf("http_requests_total{route=\"/test/panic\",status=\"500\"} = %v, want %v", got, before+1)
+	}
+}
+
+// requestCount reads http_requests_total for a GET route and status from the metrics registry
+func requestCount(t *testing.T, route, status string) float64 {
+	t.Helper()
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "http_requests_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["method"] == http.MethodGet && labels["route"] == route && labels["status"] == status {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"webui-skeleton/internal/config"
+	"webui-skeleton/internal/logger"
+)
+
+// ReloadConfig reloads the configuration and applies the live changes; source
+// names what triggered the reload in the logs
+func (s *Server) ReloadConfig(source string) ([]config.Change, error) {
+	changes, err := s.configManager.Reload()
+	if err != nil {
+		logger.Log.Error().Err(err).Str("source", source).Msg("❌ Configuration reload failed, keeping the current configuration")
+		return nil, err
+	}
+
+	logger.Log.Info().Str("source", source).Int("changes", len(changes)).Msg("🔄 Configuration reloaded")
+	logChanges(changes)
+	return changes, nil
+}
+
+// ReloadSecrets reads the secrets again and applies the ones that were rotated
+func (s *Server) ReloadSecrets() error {
+	changes, err := s.configManager.ReloadSecrets()
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("❌ Failed to reload secrets, keeping the current ones")
+		return err
+	}
+
+	logChanges(changes)
+	return nil
+}
+
+// applyConfig is subscribed to the configuration manager to apply live changes
+func (s *Server) applyConfig(cfg *config.Config, changes []config.Change) {
+	authChanged := false
+	for _, change := range changes {
+		switch {
+		case change.Setting == "log_level":
+			logger.SetLevel(cfg.LogLevel)
+		case change.Setting == "auth.require_auth":
+			s.requireAuth.Store(cfg.Auth.RequireAuth)
+		}
+		if strings.HasPrefix(change.Setting, "auth.") {
+			authChanged = true
+		}
+	}
+
+	if authChanged {
+		s.authService.UpdateConfig(&cfg.Auth)
+	}
+}
+
+// logChanges logs every changed setting; secrets are already redacted
+func logChanges(changes []config.Change) {
+	for _, change := range changes {
+		if change.Live {
+			logger.Log.Info().
+				Str("setting", change.Setting).
+				Str("old", change.Old).
+				Str("new", change.New).
+				Msg("Setting applied")
+		} else {
+			logger.Log.Warn().
+				Str("setting", change.Setting).
+				Str("old", change.Old).
+				Str("new", change.New).
+				Msg("⚠️ Setting changed, restart required to apply it")
+		}
+	}
+}
+
+// handleConfigReload reloads the configuration, like SIGHUP
+func (s *Server) handleConfigReload(c *gin.Context) {
+	changes, err := s.ReloadConfig("admin API")
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "code": "invalid_config"})
+		return
+	}
+
+	applied := []config.Change{}
+	restartRequired := []config.Change{}
+	for _, change := range changes {
+		if change.Live {
+			applied = append(applied, change)
+		} else {
+			restartRequired = append(restartRequired, change)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"applied":          applied,
+		"restart_required": restartRequired,
+	})
+}
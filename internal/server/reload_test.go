@@ -0,0 +1,138 @@
+package server
+
+import (
+	"embed"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"webui-skeleton/internal/auth"
+	"webui-skeleton/internal/config"
+	"webui-skeleton/internal/database"
+)
+
+// reloadTestConfig returns a valid config file with the given log level and extra auth settings
+func reloadTestConfig(logLevel, port, auth string) string {
+	return "log_level: " + logLevel + `
+server:
+  port: ` + port + `
+auth:
+  jwt_secret: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+  session_secret: 60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752
+` + auth
+}
+
+// newReloadTestServer returns a server with applyConfig subscribed, whose
+// configuration is loaded from the returned file path
+func newReloadTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	for _, key := range []string{"CONFIG_FILE", "LOG_LEVEL", "SERVER_PORT", "REQUIRE_AUTH", "JWT_SECRET", "JWT_SECRET_FILE", "SESSION_SECRET", "SESSION_SECRET_FILE", "GOOGLE_CLIENT_ID", "GOOGLE_CLIENT_SECRET", "SECRETS_FILE"} {
+		t.Setenv(key, "")
+	}
+	previousLevel := zerolog.GlobalLevel()
+	t.Cleanup(func() { zerolog.SetGlobalLevel(previousLevel) })
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(reloadTestConfig("info", "8080", "")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-config", path}
+	cfg, err := config.LoadConfiguration(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := database.New(&config.DatabaseConfig{Type: config.SQLite, Database: filepath.Join(t.TempDir(), "test.db")})
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(config.NewManager(cfg, args), embed.FS{}, db)
+	if s.authService, err = auth.NewService(db, &cfg.Auth); err != nil {
+		t.Fatal(err)
+	}
+	s.requireAuth.Store(cfg.Auth.RequireAuth)
+	s.configManager.Subscribe(s.applyConfig)
+	return s, path
+}
+
+func TestApplyConfig(t *testing.T) {
+	s, path := newReloadTestServer(t)
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	rewritten := reloadTestConfig("debug", "9090", `  require_auth: true
+  google_client_id: client-id
+  google_client_secret: client-secret
+`)
+	if err := os.WriteFile(path, []byte(rewritten), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReloadConfig("test"); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+
+	if level := zerolog.GlobalLevel(); level != zerolog.DebugLevel {
+		t.Errorf("log level = %v, want debug", level)
+	}
+	if !s.requireAuth.Load() {
+		t.Error("require_auth was not applied")
+	}
+	if _, ok := s.authService.Provider("google"); !ok {
+		t.Error("the Google provider added by the reload is not enabled")
+	}
+	if port := s.configManager.Current().Server.Port; port != 8080 {
+		t.Errorf("port = %d, want 8080 until a restart", port)
+	}
+}
+
+func TestHandleConfigReload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, path := newReloadTestServer(t)
+	engine := gin.New()
+	engine.POST("/admin/config/reload", s.handleConfigReload)
+
+	reload := func(content string) *httptest.ResponseRecorder {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/config/reload", nil))
+		return w
+	}
+
+	w := reload(reloadTestConfig("warn", "9090", ""))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d (body %s)", w.Code, http.StatusOK, w.Body)
+	}
+	var body struct {
+		Applied         []config.Change `json:"applied"`
+		RestartRequired []config.Change `json:"restart_required"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Applied) != 1 || body.Applied[0].Setting != "log_level" {
+		t.Errorf("applied = %+v, want log_level", body.Applied)
+	}
+	if len(body.RestartRequired) != 1 || body.RestartRequired[0].Setting != "server.port" {
+		t.Errorf("restart_required = %+v, want server.port", body.RestartRequired)
+	}
+
+	// An invalid file is refused and the running configuration kept
+	before := s.configManager.Current()
+	if w := reload(reloadTestConfig("verbose", "8080", "")); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d (body %s)", w.Code, http.StatusUnprocessableEntity, w.Body)
+	}
+	if s.configManager.Current() != before {
+		t.Error("an invalid reload replaced the configuration")
+	}
+}
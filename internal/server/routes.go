@@ -2,17 +2,28 @@ package server
 
 import (
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	"webui-skeleton/internal/auth"
+	"webui-skeleton/internal/metrics"
 )
 
 // setupRoutes configures all application routes
 func (s *Server) setupRoutes() {
-	// Health check endpoints
+	// Health check endpoints: liveness, readiness, and the readiness report with the version
+	s.engine.GET("/livez", s.handlers.Health.Livez)
+	s.engine.GET("/readyz", s.handlers.Health.Readyz)
 	s.engine.GET("/health", s.handleHealth)
 	s.engine.GET("/hh", s.handleHealth)
 
+	// Prometheus metrics, unless they have their own listener
+	if s.config.Metrics.Enabled && s.config.Metrics.Listen == "" {
+		s.engine.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
+
+	// Public keys for verifying our tokens
+	s.engine.GET("/.well-known/jwks.json", s.handlers.Auth.JWKS)
+
 	// Authentication routes (unprotected)
 	s.setupAuthRoutes()
 
@@ -30,27 +41,28 @@ func (s *Server) setupRoutes() {
 func (s *Server) setupAuthRoutes() {
 	authGroup := s.engine.Group("/auth")
 	{
-		authGroup.GET("/google/login", s.handlers.Auth.GoogleLogin)
-		authGroup.GET("/google/callback", s.handlers.Auth.GoogleCallback)
-		authGroup.GET("/logout", s.handlers.Auth.Logout)  // Changed from POST to GET
-		authGroup.POST("/logout", s.handlers.Auth.Logout) // Keep POST for API compatibility
+		authGroup.GET("/:provider/login", s.handlers.Auth.ProviderLogin)
+		authGroup.GET("/:provider/callback", s.authService.OptionalMiddleware(), s.handlers.Auth.ProviderCallback)
+		authGroup.POST("/logout", s.authService.OptionalMiddleware(), s.handlers.Auth.Logout)
+		// GET only clears the cookies, so that a link or image on another site can't revoke the session
+		authGroup.GET("/logout", s.handlers.Auth.Logout)
+		authGroup.POST("/token/refresh", s.handlers.Auth.RefreshToken)
 
 		// Protected auth routes
 		protected := authGroup.Group("")
 		protected.Use(s.authService.Middleware())
 		{
 			protected.GET("/profile", s.handlers.Auth.GetProfile)
+			protected.GET("/:provider/link", s.handlers.Auth.ProviderLink)
+			protected.POST("/logout/all", s.handlers.Auth.LogoutAll)
 		}
 	}
 }
 
 // handleHealth handles the health check endpoint
 func (s *Server) handleHealth(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":    "healthy",
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"version":   "1.0.0",
-	})
+	// Delegate to the Health handler
+	s.handlers.Health.HealthCheck(c)
 }
 
 // setupWebRoutes configures web-related routes
@@ -60,13 +72,7 @@ func (s *Server) setupWebRoutes() {
 
 	// Main application routes (require authentication when REQUIRE_AUTH is true)
 	webGroup := s.engine.Group("/")
-	if s.config.Auth.RequireAuth {
-		// If authentication is required, use the mandatory middleware
-		webGroup.Use(s.authService.Middleware())
-	} else {
-		// If authentication is optional, use the optional middleware
-		webGroup.Use(s.authService.OptionalMiddleware())
-	}
+	webGroup.Use(s.webAuthMiddleware())
 	{
 		webGroup.GET("/", s.handleHomePage)
 		webGroup.GET("/dashboard", s.handlers.Home.DashboardPage)
@@ -92,29 +98,72 @@ func (s *Server) setupAPIRoutes() {
 
 		// User profile endpoints
 		protected.GET("/profile", s.handlers.Auth.GetProfile)
+
+		// Linked identities
+		protected.GET("/identities", s.handlers.Auth.ListIdentities)
+		protected.DELETE("/identities/:id", s.handlers.Auth.UnlinkIdentity)
+
+		// Personal access tokens
+		protected.GET("/tokens", s.handlers.Auth.ListAPITokens)
+		protected.POST("/tokens", s.handlers.Auth.CreateAPIToken)
+		protected.DELETE("/tokens/:id", s.handlers.Auth.RevokeAPIToken)
 	}
 }
 
-// setupAdminRoutes configures admin routes (all protected)
+// setupAdminRoutes configures admin routes (all protected, each route requires a permission)
 func (s *Server) setupAdminRoutes() {
 	adminGroup := s.engine.Group("/admin")
 	adminGroup.Use(s.authService.Middleware())
 	{
 		// Admin dashboard
-		adminGroup.GET("/", s.handleAdminDashboard)
+		adminGroup.GET("/", s.authService.RequirePermission(auth.PermSystemRead), s.handleAdminDashboard)
 
 		// User management
-		adminGroup.GET("/users", s.handleAdminUsers)
+		adminGroup.GET("/users", s.authService.RequirePermission(auth.PermUsersRead), s.handlers.Admin.ListUsers)
+		adminGroup.GET("/users/:id", s.authService.RequirePermission(auth.PermUsersRead), s.handlers.Admin.GetUser)
+		adminGroup.POST("/users/:id/disable", s.authService.RequirePermission(auth.PermUsersWrite), s.handlers.Admin.DisableUser)
+		adminGroup.POST("/users/:id/enable", s.authService.RequirePermission(auth.PermUsersWrite), s.handlers.Admin.EnableUser)
+		adminGroup.POST("/users/:id/restore", s.authService.RequirePermission(auth.PermUsersWrite), s.handlers.Admin.RestoreUser)
+		adminGroup.POST("/users/:id/logout", s.authService.RequirePermission(auth.PermUsersWrite), s.handlers.Admin.LogoutUser)
+		adminGroup.DELETE("/users/:id", s.authService.RequirePermission(auth.PermUsersWrite), s.handlers.Admin.DeleteUser)
+
+		// Roles
+		adminGroup.GET("/roles", s.authService.RequirePermission(auth.PermRolesRead), s.handlers.Admin.ListRoles)
+		adminGroup.GET("/users/:id/roles", s.authService.RequirePermission(auth.PermRolesRead), s.handlers.Admin.GetUserRoles)
+		adminGroup.POST("/users/:id/roles", s.authService.RequirePermission(auth.PermRolesWrite), s.handlers.Admin.AssignRole)
+		adminGroup.DELETE("/users/:id/roles/:role", s.authService.RequirePermission(auth.PermRolesWrite), s.handlers.Admin.RemoveRole)
 
 		// System info
-		adminGroup.GET("/system", s.handleAdminSystem)
+		adminGroup.GET("/system", s.authService.RequirePermission(auth.PermSystemRead), s.handleAdminSystem)
+
+		// Configuration
+		adminGroup.POST("/config/reload", s.authService.RequirePermission(auth.PermSystemWrite), s.handleConfigReload)
+	}
+
+	// Profiling (admins only)
+	s.setupDebugRoutes(adminGroup)
+}
+
+// webAuthMiddleware requires authentication when REQUIRE_AUTH is true and makes it
+// optional otherwise. The setting is checked per request since it can be reloaded.
+func (s *Server) webAuthMiddleware() gin.HandlerFunc {
+	required := s.authService.Middleware()
+	optional := s.authService.OptionalMiddleware()
+	return func(c *gin.Context) {
+		if s.requireAuth.Load() {
+			required(c)
+		} else {
+			optional(c)
+		}
 	}
 }
 
 // handleLoginPage handles the login page
 func (s *Server) handleLoginPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "login.html", gin.H{
-		"title": "Login - WebUI Skeleton",
+		"title":     "Login - WebUI Skeleton",
+		"returnTo":  auth.SanitizeReturnTo(c.Query("return_to")),
+		"providers": s.authService.Providers(),
 	})
 }
 
@@ -127,25 +176,7 @@ func (s *Server) handleHomePage(c *gin.Context) {
 // handleAdminDashboard handles the admin dashboard
 func (s *Server) handleAdminDashboard(c *gin.Context) {
 	c.HTML(http.StatusOK, "admin_dashboard.html", gin.H{
-		"title": "Admin Dashboard",
-	})
-}
-
-// handleAdminUsers handles the admin users page
-func (s *Server) handleAdminUsers(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Admin users endpoint",
-		"users":   []string{"admin", "user1", "user2"},
-	})
-}
-
-// handleAdminSystem handles the admin system info page
-func (s *Server) handleAdminSystem(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"message": "System info endpoint",
-		"system": gin.H{
-			"version": "1.0.0",
-			"uptime":  "running",
-		},
+		"title":         "Admin Dashboard",
+		"authenticated": true,
 	})
 }
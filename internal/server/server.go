@@ -2,9 +2,11 @@ package server
 
 import (
 	"context"
+	"crypto/x509"
 	"embed"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,67 +14,101 @@ import (
 	"webui-skeleton/internal/config"
 	"webui-skeleton/internal/database"
 	"webui-skeleton/internal/handlers"
+	"webui-skeleton/internal/health"
 	"webui-skeleton/internal/logger"
+	"webui-skeleton/internal/metrics"
 )
 
 type Server struct {
-	config      *config.Config
-	templateFS  embed.FS
-	db          *database.DB
-	authService *auth.Service
-	handlers    *handlers.Handlers
-	engine      *gin.Engine
-	httpServer  *http.Server
+	config         *config.Config
+	configManager  *config.Manager
+	templateFS     embed.FS
+	db             *database.DB
+	authService    *auth.Service
+	handlers       *handlers.Handlers
+	engine         *gin.Engine
+	httpServer     *http.Server
+	metricsServer  *http.Server
+	redirectServer *http.Server
+	certs          *certReloader
+	checks         *health.Registry
+	connections    connTracker
+
+	// requireAuth follows auth.require_auth, which can change on reload
+	requireAuth atomic.Bool
 }
 
-// New creates a new server instance
-func New(config *config.Config, templateFS embed.FS, db *database.DB) *Server {
+// New creates a new server instance for the configuration held by configManager
+func New(configManager *config.Manager, templateFS embed.FS, db *database.DB) *Server {
 	return &Server{
-		config:     config,
-		templateFS: templateFS,
-		db:         db,
+		config:        configManager.Current(),
+		configManager: configManager,
+		templateFS:    templateFS,
+		db:            db,
+		checks:        health.NewRegistry(),
 	}
 }
 
 // SetupEngine configures the Gin engine with routes and middleware
-func (s *Server) SetupEngine() {
+func (s *Server) SetupEngine() error {
 	// Set Gin mode based on debug flag
 	if !s.config.Debug {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// Create Gin engine
+	// Create Gin engine; only the trusted proxies may set the client IP and scheme
 	s.engine = gin.New()
+	if err := s.engine.SetTrustedProxies(s.config.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("failed to set trusted proxies: %w", err)
+	}
+	trustedProxies, err := parseTrustedProxies(s.config.Server.TrustedProxies)
+	if err != nil {
+		return err
+	}
 
-	// Add basic middleware
-	s.engine.Use(gin.Logger())
+	// Add basic middleware; the request logger and metrics come before recovery so they
+	// count recovered panics as 500s, and after tracing so log lines carry the trace ID
+	s.engine.Use(requestTracing())
+	s.engine.Use(requestLogger())
+	if s.config.Metrics.Enabled {
+		s.engine.Use(requestMetrics())
+	}
 	s.engine.Use(gin.Recovery())
+	s.engine.Use(transportSecurity(trustedProxies, &s.config.Server.TLS))
 
 	// Load HTML templates
-	s.engine.LoadHTMLGlob("cmd/webui-be/web/templates/*")
+	renderer, err := newPageRenderer(s.templateFS)
+	if err != nil {
+		return err
+	}
+	s.engine.HTMLRender = renderer
 
 	// Setup authentication service
-	s.authService = auth.NewService(
-		s.db.DB,
-		s.config.Auth.JWTSecret,
-		s.config.Auth.JWTExpiresIn,
-		s.config.Auth.JWTIssuer,
-		s.config.Auth.GoogleClientID,
-		s.config.Auth.GoogleClientSecret,
-		s.config.Auth.GoogleRedirectURL,
-	)
+	authService, err := auth.NewService(s.db, &s.config.Auth)
+	if err != nil {
+		return fmt.Errorf("failed to set up authentication: %w", err)
+	}
+	s.authService = authService
+
+	// Apply live configuration changes on reload
+	s.requireAuth.Store(s.config.Auth.RequireAuth)
+	s.configManager.Subscribe(s.applyConfig)
+
+	// Register the readiness checks
+	s.registerHealthChecks()
 
 	// Initialize handlers
-	s.handlers = handlers.NewHandlers(s.config, s.db, s.authService)
+	s.handlers = handlers.NewHandlers(s.config, s.db, s.authService, s.checks)
 
 	// Setup routes
 	s.setupRoutes()
 
 	logger.Log.Info().Msg("✅ Server engine configured")
+	return nil
 }
 
-// CreateHTTPServer creates the HTTP server instance
-func (s *Server) CreateHTTPServer() {
+// CreateHTTPServer creates the HTTP server instance, loading the TLS certificate when HTTPS is configured
+func (s *Server) CreateHTTPServer() error {
 	addr := fmt.Sprintf("%s:%d", s.config.Server.Host, s.config.Server.Port)
 
 	s.httpServer = &http.Server{
@@ -81,17 +117,71 @@ func (s *Server) CreateHTTPServer() {
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
+		ConnState:    s.connections.track,
+	}
+
+	tlsConfig := &s.config.Server.TLS
+	if tlsConfig.Enabled() {
+		certs, err := newCertReloader(tlsConfig.CertFile, tlsConfig.KeyFile)
+		if err != nil {
+			return err
+		}
+		s.certs = certs
+
+		// Services authenticate with client certificates verified against these CAs
+		var clientCAs *x509.CertPool
+		if tlsConfig.ClientCAFile != "" {
+			if clientCAs, err = loadCertPool(tlsConfig.ClientCAFile); err != nil {
+				return err
+			}
+		}
+		s.httpServer.TLSConfig = newTLSConfig(tlsConfig, certs, clientCAs)
+
+		if tlsConfig.RedirectListen != "" {
+			s.redirectServer = &http.Server{
+				Addr:              tlsConfig.RedirectListen,
+				Handler:           redirectToHTTPS(s.config.Server.Port),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+		}
 	}
 
 	logger.Log.Info().
 		Str("address", addr).
+		Bool("tls", tlsConfig.Enabled()).
+		Bool("client_certificates", tlsConfig.ClientCAFile != "").
 		Msg("HTTP server configured")
+
+	// Metrics on their own listener stay off the public server
+	if s.config.Metrics.Enabled && s.config.Metrics.Listen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		s.metricsServer = &http.Server{
+			Addr:              s.config.Metrics.Listen,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+	}
+	return nil
 }
 
 // Start starts the HTTP server
 func (s *Server) Start(ctx context.Context) error {
 	// Start server in a goroutine
 	go func() {
+		if s.certs != nil {
+			logger.Log.Info().
+				Str("address", s.httpServer.Addr).
+				Time("certificate_expires", s.certs.expires()).
+				Msg("🔒 Starting HTTPS server")
+
+			// The certificate comes from TLSConfig.GetCertificate
+			if err := s.httpServer.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				logger.Log.Fatal().Err(err).Msg("❌ HTTPS server failed to start")
+			}
+			return
+		}
+
 		logger.Log.Info().
 			Str("address", s.httpServer.Addr).
 			Msg("🚀 Starting HTTP server")
@@ -101,15 +191,62 @@ func (s *Server) Start(ctx context.Context) error {
 		}
 	}()
 
+	if s.certs != nil && s.config.Server.TLS.ReloadInterval > 0 {
+		go s.certs.watch(ctx, s.config.Server.TLS.ReloadInterval)
+	}
+
+	if s.redirectServer != nil {
+		go func() {
+			logger.Log.Info().
+				Str("address", s.redirectServer.Addr).
+				Msg("↪️ Starting HTTP to HTTPS redirect server")
+
+			if err := s.redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Log.Fatal().Err(err).Msg("❌ Redirect server failed to start")
+			}
+		}()
+	}
+
+	if s.metricsServer != nil {
+		go func() {
+			logger.Log.Info().
+				Str("address", s.metricsServer.Addr).
+				Msg("📈 Starting metrics server")
+
+			if err := s.metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Log.Fatal().Err(err).Msg("❌ Metrics server failed to start")
+			}
+		}()
+	}
+
 	// Wait for shutdown signal
 	<-ctx.Done()
 
+	// Fail readiness first, so that load balancers stop sending requests before the listener closes
+	s.checks.SetDraining()
+	if delay := s.config.Server.ShutdownDelay; delay > 0 {
+		logger.Log.Info().Dur("delay", delay).Msg("⏳ Draining before shutdown")
+		time.Sleep(delay)
+	}
+
 	// Graceful shutdown
 	logger.Log.Info().Msg("🛑 Shutting down HTTP server")
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if s.metricsServer != nil {
+		if err := s.metricsServer.Shutdown(shutdownCtx); err != nil {
+			logger.Log.Error().Err(err).Msg("❌ Metrics server forced to shutdown")
+		}
+	}
+
+	if s.redirectServer != nil {
+		if err := s.redirectServer.Shutdown(shutdownCtx); err != nil {
+			logger.Log.Error().Err(err).Msg("❌ Redirect server forced to shutdown")
+		}
+	}
+
 	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
 		logger.Log.Error().Err(err).Msg("❌ HTTP server forced to shutdown")
 		return err
@@ -118,3 +255,19 @@ func (s *Server) Start(ctx context.Context) error {
 	logger.Log.Info().Msg("✅ HTTP server stopped gracefully")
 	return nil
 }
+
+// registerHealthChecks adds the dependency checks run by /readyz
+func (s *Server) registerHealthChecks() {
+	s.checks.Register(health.Check{Name: "database", Run: s.db.DB.PingContext})
+	s.checks.Register(health.Check{Name: "migrations", Run: s.db.CheckMigrations})
+
+	// A provider outage blocks new logins but not existing sessions, so it only degrades readiness
+	if s.config.Auth.GoogleEnabled() || s.config.Auth.OIDCEnabled() {
+		s.checks.Register(health.Check{
+			Name:     "oauth_jwks",
+			Run:      s.authService.CheckJWKS,
+			Timeout:  5 * time.Second,
+			Optional: true,
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"webui-skeleton/internal/auth"
+	"webui-skeleton/internal/logger"
+	"webui-skeleton/internal/version"
+)
+
+// recentGCPauses is how many of the latest GC pauses /admin/system lists
+const recentGCPauses = 10
+
+// connTracker counts the open HTTP connections and those serving a request,
+// as reported to http.Server.ConnState
+type connTracker struct {
+	open   atomic.Int64
+	active atomic.Int64
+
+	// states holds the last state of each connection, to tell active ones apart on close
+	states sync.Map
+}
+
+// track implements http.Server.ConnState
+func (t *connTracker) track(conn net.Conn, state http.ConnState) {
+	previous, _ := t.states.Load(conn)
+	if previous == http.StateActive {
+		t.active.Add(-1)
+	}
+
+	switch state {
+	case http.StateNew:
+		t.open.Add(1)
+	case http.StateActive:
+		t.active.Add(1)
+	case http.StateHijacked, http.StateClosed:
+		t.open.Add(-1)
+		t.states.Delete(conn)
+		return
+	}
+	t.states.Store(conn, state)
+}
+
+// setupDebugRoutes serves the pprof profiles and a goroutine dump, for admins only
+func (s *Server) setupDebugRoutes(adminGroup *gin.RouterGroup) {
+	debugGroup := adminGroup.Group("/debug", s.authService.RequirePermission(auth.PermSystemDebug))
+	{
+		debugGroup.GET("/goroutines", s.handleGoroutineDump)
+
+		// pprof.Index finds profiles by a /debug/pprof/ path prefix, so named profiles are routed here
+		debugGroup.GET("/pprof/", gin.WrapF(pprof.Index))
+		debugGroup.GET("/pprof/cmdline", gin.WrapF(pprof.Cmdline))
+		debugGroup.GET("/pprof/profile", gin.WrapF(pprof.Profile))
+		debugGroup.GET("/pprof/symbol", gin.WrapF(pprof.Symbol))
+		debugGroup.POST("/pprof/symbol", gin.WrapF(pprof.Symbol))
+		debugGroup.GET("/pprof/trace", gin.WrapF(pprof.Trace))
+		debugGroup.GET("/pprof/:profile", func(c *gin.Context) {
+			pprof.Handler(c.Param("profile")).ServeHTTP(c.Writer, c.Request)
+		})
+	}
+}
+
+// handleGoroutineDump downloads the stacks of all goroutines as text
+func (s *Server) handleGoroutineDump(c *gin.Context) {
+	logger.FromContext(c.Request.Context()).Info().Msg("Goroutine dump downloaded")
+
+	filename := fmt.Sprintf("goroutines-%s.txt", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// debug=2 prints every goroutine with its full stack, like an unrecovered panic
+	pprof.Handler("goroutine").ServeHTTP(c.Writer, withQuery(c.Request, "debug=2"))
+}
+
+// withQuery returns a copy of r with its query string replaced
+func withQuery(r *http.Request, query string) *http.Request {
+	r = r.Clone(r.Context())
+	r.URL.RawQuery = query
+	return r
+}
+
+// handleAdminSystem reports runtime, database, HTTP and host diagnostics
+func (s *Server) handleAdminSystem(c *gin.Context) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	sessions, err := s.authService.CountActiveSessions(c.Request.Context())
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error().Err(err).Msg("Failed to count sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count sessions"})
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	dbStats := s.db.DB.Stats()
+	uptime := s.checks.Uptime()
+
+	c.JSON(http.StatusOK, gin.H{
+		"version": version.Get().Version,
+		"build":   version.Get(),
+		"uptime":  uptime.Truncate(time.Second).String(),
+		"started": time.Now().Add(-uptime).UTC().Format(time.RFC3339),
+		"runtime": gin.H{
+			"goroutines": runtime.NumGoroutine(),
+			"gomaxprocs": runtime.GOMAXPROCS(0),
+			"num_cpu":    runtime.NumCPU(),
+		},
+		"memory": gin.H{
+			"alloc_bytes":       mem.Alloc,
+			"total_alloc_bytes": mem.TotalAlloc,
+			"sys_bytes":         mem.Sys,
+			"heap_inuse_bytes":  mem.HeapInuse,
+			"heap_objects":      mem.HeapObjects,
+			"stack_inuse_bytes": mem.StackInuse,
+		},
+		"gc": gcStats(&mem),
+		"database": gin.H{
+			"type":                 s.config.Database.Type,
+			"open_connections":     dbStats.OpenConnections,
+			"in_use":               dbStats.InUse,
+			"idle":                 dbStats.Idle,
+			"max_open_connections": dbStats.MaxOpenConnections,
+			"wait_count":           dbStats.WaitCount,
+			"wait_duration_ms":     dbStats.WaitDuration.Milliseconds(),
+			"max_idle_closed":      dbStats.MaxIdleClosed,
+			"max_lifetime_closed":  dbStats.MaxLifetimeClosed,
+		},
+		"http": gin.H{
+			"open_connections":   s.connections.open.Load(),
+			"active_connections": s.connections.active.Load(),
+		},
+		"sessions": gin.H{
+			"active": sessions,
+		},
+		"host": gin.H{
+			"hostname": hostname,
+			"os":       runtime.GOOS,
+			"arch":     runtime.GOARCH,
+			"pid":      os.Getpid(),
+		},
+		"config": s.configManager.Current().Redacted().Settings(),
+	})
+}
+
+// gcStats summarizes the garbage collector, with the latest pauses first
+func gcStats(mem *runtime.MemStats) gin.H {
+	n := min(int(mem.NumGC), recentGCPauses)
+	pauses := make([]float64, n)
+	for i := range pauses {
+		// PauseNs is a circular buffer; the latest pause is at (NumGC+255)%256
+		pause := mem.PauseNs[(int(mem.NumGC)-1-i+len(mem.PauseNs))%len(mem.PauseNs)]
+		pauses[i] = float64(pause) / float64(time.Millisecond)
+	}
+
+	stats := gin.H{
+		"num_gc":           mem.NumGC,
+		"pause_total_ms":   float64(mem.PauseTotalNs) / float64(time.Millisecond),
+		"recent_pauses_ms": pauses,
+		"next_gc_bytes":    mem.NextGC,
+		"cpu_fraction":     mem.GCCPUFraction,
+	}
+	if mem.LastGC > 0 {
+		stats["last_gc"] = time.Unix(0, int64(mem.LastGC)).UTC().Format(time.RFC3339)
+	}
+	return stats
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin/render"
+)
+
+const (
+	templateDir  = "web/templates"
+	baseTemplate = "base.html"
+)
+
+// templateFuncs are available to every page template
+var templateFuncs = template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+	"sub": func(a, b int) int { return a - b },
+	"formatTime": func(t interface{}) string {
+		switch v := t.(type) {
+		case time.Time:
+			return v.Format("2006-01-02 15:04")
+		case *time.Time:
+			if v != nil {
+				return v.Format("2006-01-02 15:04")
+			}
+		}
+		return ""
+	},
+}
+
+// pageRenderer renders each page inside base.html. Every page defines its own
+// "content" block, so pages are parsed into separate template sets instead of
+// one set where the last "content" definition would win.
+type pageRenderer struct {
+	pages map[string]*template.Template
+}
+
+// newPageRenderer parses every page in the template directory together with base.html
+func newPageRenderer(fsys fs.FS) (*pageRenderer, error) {
+	files, err := fs.Glob(fsys, path.Join(templateDir, "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+
+	r := &pageRenderer{pages: make(map[string]*template.Template)}
+	for _, file := range files {
+		name := path.Base(file)
+		if name == baseTemplate {
+			continue
+		}
+
+		tmpl, err := template.New(name).Funcs(templateFuncs).ParseFS(fsys, path.Join(templateDir, baseTemplate), file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+		}
+		r.pages[name] = tmpl
+	}
+
+	return r, nil
+}
+
+// Instance implements render.HTMLRender
+func (r *pageRenderer) Instance(name string, data interface{}) render.Render {
+	tmpl, ok := r.pages[name]
+	if !ok {
+		// Executing an empty set fails with a "no such template" error for gin to record
+		return render.HTML{Template: template.New(""), Name: name, Data: data}
+	}
+
+	return render.HTML{Template: tmpl, Name: baseTemplate, Data: data}
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"webui-skeleton/internal/config"
+	"webui-skeleton/internal/logger"
+)
+
+// intermediateCipherSuites are the forward-secret AEAD suites offered for TLS 1.2
+// by the intermediate policy; TLS 1.3 suites are not configurable
+var intermediateCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// newTLSConfig builds the server TLS settings for cfg, serving the certificates held by certs.
+// Client certificates are requested when clientCAs is set; clients without one still connect.
+func newTLSConfig(cfg *config.TLSConfig, certs *certReloader, clientCAs *x509.CertPool) *tls.Config {
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.getCertificate,
+	}
+	if clientCAs != nil {
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	if cfg.MinVersion == "1.3" || cfg.CipherPolicy == "modern" {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+	if cfg.CipherPolicy == "intermediate" {
+		tlsConfig.CipherSuites = intermediateCipherSuites
+	}
+	return tlsConfig
+}
+
+// loadCertPool reads a bundle of PEM CA certificates
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("failed to parse client CA bundle %s: no PEM certificates found", path)
+	}
+	return pool, nil
+}
+
+// certReloader serves the certificate in a pair of PEM files and loads it again
+// when either file changes, so that renewed certificates apply without a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+
+	// certModTime and keyModTime are those of the files the current certificate was loaded from
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// newCertReloader loads the certificate in certFile and its private key in keyFile
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// getCertificate implements tls.Config.GetCertificate
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// reload loads the certificate again if either file changed since the last load,
+// and reports whether it did. The current certificate stays in use on errors,
+// such as a renewal that has replaced the certificate but not yet the key.
+func (r *certReloader) reload() (bool, error) {
+	certModTime, err := modTime(r.certFile)
+	if err != nil {
+		return false, err
+	}
+	keyModTime, err := modTime(r.keyFile)
+	if err != nil {
+		return false, err
+	}
+	if certModTime.Equal(r.certModTime) && keyModTime.Equal(r.keyModTime) {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	r.cert.Store(&cert)
+	r.certModTime, r.keyModTime = certModTime, keyModTime
+	return true, nil
+}
+
+// watch checks the certificate files for changes every interval until ctx is done
+func (r *certReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.reload()
+			if err != nil {
+				logger.Log.Error().Err(err).Str("cert_file", r.certFile).Msg("❌ Failed to reload TLS certificate, keeping the current one")
+				continue
+			}
+			if reloaded {
+				logger.Log.Info().Str("cert_file", r.certFile).Time("expires", r.expires()).Msg("🔒 TLS certificate reloaded")
+			}
+		}
+	}
+}
+
+// expires returns when the current certificate expires
+func (r *certReloader) expires() time.Time {
+	if leaf := r.cert.Load().Leaf; leaf != nil {
+		return leaf.NotAfter
+	}
+	return time.Time{}
+}
+
+// modTime returns the modification time of the file at path
+func modTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to check TLS file: %w", err)
+	}
+	return info.ModTime(), nil
+}
+
+// redirectToHTTPS redirects plain HTTP requests to the same URL on the HTTPS port
+func redirectToHTTPS(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if host == "" {
+			http.Error(w, "Missing Host header", http.StatusBadRequest)
+			return
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		// 308 keeps the method and body of non-GET requests
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"webui-skeleton/internal/auth"
+	"webui-skeleton/internal/config"
+)
+
+// writeTestCertificate writes a self-signed certificate for commonName and its key
+// as PEM. modified sets the modification time of both files.
+func writeTestCertificate(t *testing.T, certFile, keyFile, commonName string, modified time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePEM(t, certFile, "CERTIFICATE", der, modified)
+	if keyFile != "" {
+		writePEM(t, keyFile, "EC PRIVATE KEY", keyDER, modified)
+	}
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte, modified time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// servedCommonName connects to a TLS server and returns the common name of its certificate
+func servedCommonName(t *testing.T, addr string) string {
+	t.Helper()
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: "localhost", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	modified := time.Now().Add(-time.Hour)
+	writeTestCertificate(t, certFile, keyFile, "first", modified)
+
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newCertReloader() error = %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = newTLSConfig(&config.TLSConfig{}, certs, nil)
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+
+	if name := servedCommonName(t, addr); name != "first" {
+		t.Fatalf("served certificate = %s, want first", name)
+	}
+	if reloaded, err := certs.reload(); reloaded || err != nil {
+		t.Errorf("reload() of unchanged files = %t, %v, want no reload", reloaded, err)
+	}
+
+	// A renewed pair is served to new connections without a restart
+	modified = modified.Add(time.Minute)
+	writeTestCertificate(t, certFile, keyFile, "second", modified)
+	if reloaded, err := certs.reload(); !reloaded || err != nil {
+		t.Fatalf("reload() of a renewed pair = %t, %v, want a reload", reloaded, err)
+	}
+	if name := servedCommonName(t, addr); name != "second" {
+		t.Errorf("served certificate = %s after the renewal, want second", name)
+	}
+
+	// A certificate that doesn't match the key yet keeps the current pair in use
+	modified = modified.Add(time.Minute)
+	writeTestCertificate(t, certFile, "", "third", modified)
+	if reloaded, err := certs.reload(); reloaded || err == nil {
+		t.Errorf("reload() of a mismatched pair = %t, %v, want an error", reloaded, err)
+	}
+	if name := servedCommonName(t, addr); name != "second" {
+		t.Errorf("served certificate = %s after a failed reload, want second", name)
+	}
+
+	// Once the key catches up, the next check loads the pair
+	modified = modified.Add(time.Minute)
+	writeTestCertificate(t, certFile, keyFile, "fourth", modified)
+	if reloaded, err := certs.reload(); !reloaded || err != nil {
+		t.Fatalf("reload() after a failed one = %t, %v, want a reload", reloaded, err)
+	}
+	if name := servedCommonName(t, addr); name != "fourth" {
+		t.Errorf("served certificate = %s, want fourth", name)
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name      string
+		host      string
+		httpsPort int
+		target    string
+		want      string
+	}{
+		{name: "host", host: "example.com", httpsPort: 443, target: "/dashboard?tab=1", want: "https://example.com/dashboard?tab=1"},
+		{name: "host with port 80", host: "example.com:80", httpsPort: 443, target: "/", want: "https://example.com/"},
+		{name: "custom HTTPS port", host: "example.com:80", httpsPort: 8443, target: "/login", want: "https://example.com:8443/login"},
+		{name: "IPv6", host: "[::1]:80", httpsPort: 443, target: "/", want: "https://[::1]/"},
+		{name: "IPv6 without port", host: "[::1]", httpsPort: 443, target: "/", want: "https://[::1]/"},
+		{name: "IPv6 custom HTTPS port", host: "[::1]:80", httpsPort: 8443, target: "/api?x=1", want: "https://[::1]:8443/api?x=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, method := range []string{http.MethodGet, http.MethodPost} {
+				r := httptest.NewRequest(method, tt.target, nil)
+				r.Host = tt.host
+				w := httptest.NewRecorder()
+				redirectToHTTPS(tt.httpsPort).ServeHTTP(w, r)
+
+				if w.Code != http.StatusPermanentRedirect {
+					t.Errorf("%s status = %d, want %d", method, w.Code, http.StatusPermanentRedirect)
+				}
+				if location := w.Header().Get("Location"); location != tt.want {
+					t.Errorf("%s Location = %q, want %q", method, location, tt.want)
+				}
+			}
+		})
+	}
+
+	t.Run("missing host", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = ""
+		w := httptest.NewRecorder()
+		redirectToHTTPS(443).ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestTransportSecurity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	trustedProxies, err := parseTrustedProxies([]string{"10.0.0.1", "192.168.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		tls            bool
+		remoteAddr     string
+		forwardedProto string
+		wantHTTPS      bool
+	}{
+		{name: "plain HTTP", remoteAddr: "10.0.0.1:1234"},
+		{name: "TLS", tls: true, remoteAddr: "203.0.113.7:1234", wantHTTPS: true},
+		{name: "trusted proxy forwarding HTTPS", remoteAddr: "10.0.0.1:1234", forwardedProto: "https", wantHTTPS: true},
+		{name: "trusted proxy range forwarding HTTPS", remoteAddr: "192.168.4.2:1234", forwardedProto: "HTTPS", wantHTTPS: true},
+		{name: "trusted proxy forwarding HTTP", remoteAddr: "10.0.0.1:1234", forwardedProto: "http"},
+		{name: "untrusted peer claiming HTTPS", remoteAddr: "203.0.113.7:1234", forwardedProto: "https"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, hstsMaxAge := range []time.Duration{0, 365 * 24 * time.Hour} {
+				engine := gin.New()
+				engine.Use(transportSecurity(trustedProxies, &config.TLSConfig{HSTSMaxAge: hstsMaxAge, HSTSIncludeSubdomains: true}))
+				engine.GET("/", func(c *gin.Context) {
+					auth.SetCookie(c, "session", "value", 60, "/")
+					c.Status(http.StatusNoContent)
+				})
+
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.RemoteAddr = tt.remoteAddr
+				if tt.forwardedProto != "" {
+					r.Header.Set("X-Forwarded-Proto", tt.forwardedProto)
+				}
+				if tt.tls {
+					r.TLS = &tls.ConnectionState{}
+				}
+				w := httptest.NewRecorder()
+				engine.ServeHTTP(w, r)
+
+				wantHSTS := ""
+				if tt.wantHTTPS && hstsMaxAge > 0 {
+					wantHSTS = "max-age=31536000; includeSubDomains"
+				}
+				if hsts := w.Header().Get("Strict-Transport-Security"); hsts != wantHSTS {
+					t.Errorf("HSTS max age %s: Strict-Transport-Security = %q, want %q", hstsMaxAge, hsts, wantHSTS)
+				}
+				cookie := w.Header().Get("Set-Cookie")
+				if secure := strings.Contains(cookie, "; Secure"); secure != tt.wantHTTPS {
+					t.Errorf("Set-Cookie = %q, want Secure %t", cookie, tt.wantHTTPS)
+				}
+				if !strings.Contains(cookie, "; HttpOnly") {
+					t.Errorf("Set-Cookie = %q, want HttpOnly", cookie)
+				}
+			}
+		})
+	}
+}
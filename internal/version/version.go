@@ -0,0 +1,117 @@
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X webui-skeleton/internal/version.version=v1.2.3 \
+//	  -X webui-skeleton/internal/version.commit=$(git rev-parse HEAD) \
+//	  -X webui-skeleton/internal/version.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/webui-be
+//
+// Values that aren't set are taken from the build info Go embeds in the binary.
+var (
+	version   string
+	commit    string
+	buildTime string
+)
+
+// Info describes the build of the running binary
+type Info struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit"`
+	Dirty      bool   `json:"dirty"`
+	CommitTime string `json:"commit_time,omitempty"`
+	BuildTime  string `json:"build_time,omitempty"`
+	GoVersion  string `json:"go_version"`
+	Module     string `json:"module"`
+
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+}
+
+// Dependency is a module compiled into the binary
+type Dependency struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Replace string `json:"replace,omitempty"`
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the build information, read once
+func Get() Info {
+	once.Do(func() { info = read() })
+	return info
+}
+
+// read combines the ldflags values with the build info embedded by the Go toolchain
+func read() Info {
+	i := Info{
+		Version:   version,
+		Commit:    commit,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		i.Module = build.Main.Path
+		// go install module@version records the version; local builds record (devel)
+		if i.Version == "" && build.Main.Version != "(devel)" {
+			i.Version = build.Main.Version
+		}
+
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if i.Commit == "" {
+					i.Commit = setting.Value
+				}
+			case "vcs.modified":
+				i.Dirty = setting.Value == "true"
+			case "vcs.time":
+				i.CommitTime = setting.Value
+			}
+		}
+
+		for _, dep := range build.Deps {
+			d := Dependency{Path: dep.Path, Version: dep.Version}
+			if dep.Replace != nil {
+				d.Replace = strings.TrimSpace(dep.Replace.Path + " " + dep.Replace.Version)
+			}
+			i.Dependencies = append(i.Dependencies, d)
+		}
+	}
+
+	if i.Version == "" {
+		i.Version = "dev"
+	}
+	if i.Commit == "" {
+		i.Commit = "unknown"
+	}
+	return i
+}
+
+// ShortCommit returns the first 12 characters of the commit hash
+func (i Info) ShortCommit() string {
+	if len(i.Commit) > 12 {
+		return i.Commit[:12]
+	}
+	return i.Commit
+}
+
+// String returns a one-line description such as "v1.2.3 (3f2a1b9c0d4e, dirty)"
+func (i Info) String() string {
+	s := fmt.Sprintf("%s (%s", i.Version, i.ShortCommit())
+	if i.Dirty {
+		s += ", dirty"
+	}
+	return s + ")"
+}